@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/aeneasr/dockerstats/chart"
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+const (
+	// imageMaxAge is how long badges and charts may be cached.
+	imageMaxAge = time.Hour
+
+	badgeBlue  = "#007ec6"
+	badgeGreen = "#4c1"
+	badgeGrey  = "#9f9f9f"
+)
+
+// badge renders a shields.io style badge of a repository's latest pull or star
+// count or of its pull growth over the last window days. The metric is chosen
+// by the metric query parameter (pulls, stars or growth).
+func (h *Handler) badge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	registry, slug := registryParam(r), vars["org"]+"/"+vars["repo"]
+
+	window, err := intParam(r, "window", 7, 30)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var label, value, color string
+	switch metric := r.URL.Query().Get("metric"); metric {
+	case "", "pulls", "stars":
+		latest, err := h.s.FindLatestSnapshot(r.Context(), registry, slug)
+		if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+			h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			h.w.WriteError(w, r, err)
+			return
+		}
+
+		label, value, color = "docker pulls", "n/a", badgeGrey
+		if metric == "stars" {
+			label = "docker stars"
+		}
+		if latest != nil {
+			value, color = chart.FormatCount(latest.Pulls), badgeBlue
+			if metric == "stars" {
+				value = chart.FormatCount(latest.Stars)
+			}
+			w.Header().Set("Last-Modified", latest.Timestamp.UTC().Format(http.TimeFormat))
+		}
+	case "growth":
+		history, err := h.s.FindSnapshots(r.Context(), registry, slug, time.Now().UTC().AddDate(0, 0, -window), time.Time{})
+		if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+			h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			h.w.WriteError(w, r, err)
+			return
+		}
+
+		label, value, color = fmt.Sprintf("pulls/%dd", window), "n/a", badgeGrey
+		if n := len(history); n > 1 {
+			growth := history[n-1].Pulls - history[0].Pulls
+			value = "+" + chart.FormatCount(growth)
+			if growth < 0 {
+				value = chart.FormatCount(growth)
+			}
+			if growth > 0 {
+				color = badgeGreen
+			}
+			w.Header().Set("Last-Modified", history[n-1].Timestamp.UTC().Format(http.TimeFormat))
+		}
+	default:
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter metric must be one of pulls, stars or growth"))
+		return
+	}
+
+	if l := r.URL.Query().Get("label"); l != "" {
+		label = l
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml;charset=utf-8")
+	cacheImage(w)
+	_, _ = w.Write(renderBadge(label, value, color))
+}
+
+func cacheImage(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(imageMaxAge.Seconds())))
+	w.Header().Set("Expires", time.Now().Add(imageMaxAge).UTC().Format(http.TimeFormat))
+}
+
+// textWidth approximates the width of s in 11px Verdana.
+func textWidth(s string) int {
+	var w int
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune(" .,:;'!|/()[]-iIjlftr1", c):
+			w += 4
+		case strings.ContainsRune("mwMW%@", c):
+			w += 10
+		default:
+			w += 7
+		}
+	}
+	return w
+}
+
+func renderBadge(label, value, color string) []byte {
+	lw, vw := textWidth(label)+10, textWidth(value)+10
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		lw+vw, lw, vw, label, value, color, lw/2, lw+vw/2,
+	))
+}
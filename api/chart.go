@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/aeneasr/dockerstats/chart"
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+// chartImage renders a repository's pull or star history between from and to as
+// SVG or PNG image depending on the file extension.
+func (h *Handler) chartImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	registry, slug := registryParam(r), vars["org"]+"/"+vars["repo"]
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	c := chart.Chart{}
+	if c.Width, err = intParam(r, "width", 800, 2000); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if c.Height, err = intParam(r, "height", 400, 1000); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if c.Theme, err = chart.ParseTheme(r.URL.Query().Get("theme")); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if metric != "" && metric != "pulls" && metric != "stars" {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter metric must be one of pulls or stars"))
+		return
+	}
+
+	history, err := h.s.FindSnapshots(r.Context(), registry, slug, from, to)
+	if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	c.Points = make([]chart.Point, len(history))
+	for k, s := range history {
+		c.Points[k] = chart.Point{Time: s.Timestamp, Value: float64(s.Pulls)}
+		if metric == "stars" {
+			c.Points[k].Value = float64(s.Stars)
+		}
+	}
+
+	var b bytes.Buffer
+	contentType := "image/svg+xml;charset=utf-8"
+	if vars["format"] == "png" {
+		contentType = "image/png"
+		err = c.PNG(&b)
+	} else {
+		err = c.SVG(&b)
+	}
+	if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	cacheImage(w)
+	_, _ = w.Write(b.Bytes())
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// responseFormat returns the format selected by the format query parameter or,
+// if it is not set, by the Accept header. It defaults to def.
+func responseFormat(r *http.Request, def string) (string, error) {
+	switch f := r.URL.Query().Get("format"); f {
+	case "":
+	case formatJSON, formatCSV, formatNDJSON:
+		return f, nil
+	default:
+		return "", errors.Errorf("query parameter format must be one of json, csv or ndjson")
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return formatJSON, nil
+		case "text/csv":
+			return formatCSV, nil
+		case "application/x-ndjson", "application/ndjson":
+			return formatNDJSON, nil
+		}
+	}
+	return def, nil
+}
+
+// snapshotEncoder writes snapshot records one by one.
+type snapshotEncoder interface {
+	encode(r *scrap.SnapshotRecord) error
+	flush() error
+}
+
+func newSnapshotEncoder(w http.ResponseWriter, format, filename string) snapshotEncoder {
+	if format == formatCSV {
+		w.Header().Set("Content-Type", "text/csv;charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		return newCSVEncoder(w)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	return &ndjsonEncoder{e: json.NewEncoder(w)}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+// newCSVEncoder buffers the header, which is written with the first record or
+// on flush.
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	_ = e.w.Write([]string{"registry", "slug", "timestamp", "pull_count", "pull_count_raw", "star_count"})
+	return e
+}
+
+func (e *csvEncoder) encode(r *scrap.SnapshotRecord) error {
+	return errors.WithStack(e.w.Write([]string{
+		r.Registry,
+		r.Slug,
+		r.Timestamp.UTC().Format(time.RFC3339),
+		strconv.FormatInt(r.Pulls, 10),
+		strconv.FormatInt(r.PullsRaw, 10),
+		strconv.FormatInt(r.Stars, 10),
+	}))
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return errors.WithStack(e.w.Error())
+}
+
+type ndjsonEncoder struct {
+	e *json.Encoder
+}
+
+func (e *ndjsonEncoder) encode(r *scrap.SnapshotRecord) error {
+	return errors.WithStack(e.e.Encode(r))
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
+
+// streamSnapshots writes the snapshots selected by q as CSV or NDJSON. Once the
+// first record was written, errors can no longer be reported to the client and
+// abort the connection instead so that the truncated response is not mistaken
+// for a complete one. A single repository which is not known yet is reported
+// as not found instead of streaming no snapshots.
+func (h *Handler) streamSnapshots(w http.ResponseWriter, r *http.Request, q scrap.SnapshotQuery, format, filename string) {
+	if len(q.Slugs) == 1 {
+		_, err := h.s.FindLatestSnapshot(r.Context(), q.Registry, q.Slugs[0])
+		if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+			h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			h.w.WriteError(w, r, err)
+			return
+		}
+	}
+
+	var e snapshotEncoder
+	err := h.s.WalkSnapshots(r.Context(), q, func(record *scrap.SnapshotRecord) error {
+		if e == nil {
+			e = newSnapshotEncoder(w, format, filename)
+		}
+		return e.encode(record)
+	})
+	if e == nil {
+		if errors.Is(err, scrap.ErrUnknownRegistry) {
+			h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			h.w.WriteError(w, r, err)
+			return
+		}
+		e = newSnapshotEncoder(w, format, filename)
+	}
+
+	if err == nil {
+		err = e.flush()
+	}
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// export streams the snapshots of the repositories given by the repos query
+// parameter or of all repositories whose slug starts with prefix.
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	format, err := responseFormat(r, formatNDJSON)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if format == formatJSON {
+		format = formatNDJSON
+	}
+
+	q := scrap.SnapshotQuery{Registry: registryParam(r), Slugs: slugsParam(r), Prefix: r.URL.Query().Get("prefix")}
+	if len(q.Slugs) == 0 && q.Prefix == "" {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter repos or prefix is required"))
+		return
+	}
+	if q.From, q.To, err = timeRange(r); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	h.streamSnapshots(w, r, q, format, "snapshots")
+}
@@ -1,8 +1,9 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -22,25 +23,60 @@ func NewHandler(s *scrap.Scraper, w herodot.Writer) *Handler {
 }
 
 func (h *Handler) Handle(r *mux.Router) {
+	r.Use(instrument)
 	r.HandleFunc("/snapshots/repositories", h.query)
+	r.HandleFunc("/snapshots/repositories/growth", h.growth)
+	r.HandleFunc("/snapshots/export", h.export)
+	r.HandleFunc("/tags/repositories", h.tags)
+	r.HandleFunc("/tags/repositories/cadence", h.tagCadence)
+	r.HandleFunc("/tags/repositories/sizes", h.tagSizes)
 	r.HandleFunc("/discovery/repositories", h.images)
+	r.HandleFunc("/leaderboards", h.leaderboard)
+	r.HandleFunc("/orgs/{org}", h.organization)
+	r.HandleFunc("/compare", h.compare)
+	r.HandleFunc("/badges/{org}/{repo}.svg", h.badge)
+	r.HandleFunc("/charts/{org}/{repo}.{format:svg|png}", h.chartImage)
 	r.HandleFunc("/stats", h.stats)
 }
 
 func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
-	org := r.URL.Query().Get("org")
-	repo := r.URL.Query().Get("repo")
+	registry, slug, err := repository(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-	if org == "" {
-		org = "library"
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
 	}
-	if repo == "" {
-		h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter repo is empty"))
+
+	resolution, err := bucketResolution(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	history, err := h.s.FindSnapshots(fmt.Sprintf("%s/%s", org, repo))
+	format, err := responseFormat(r, formatJSON)
 	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if format != formatJSON {
+		if resolution != "" {
+			h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter resolution is only supported for json"))
+			return
+		}
+		q := scrap.SnapshotQuery{Registry: registry, Slugs: []string{slug}, From: from, To: to}
+		h.streamSnapshots(w, r, q, format, strings.Replace(slug, "/", "_", -1))
+		return
+	}
+
+	history, err := h.s.SearchSnapshots(r.Context(), registry, slug, from, to)
+	if errors.Is(err, scrap.ErrUnknownRegistry) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
 		h.w.WriteError(w, r, err)
 		return
 	}
@@ -49,17 +85,215 @@ func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
 		history = scrap.RepositorySnapshots{}
 	}
 
+	if resolution != "" {
+		h.w.Write(w, r, history.Bucket(resolution))
+		return
+	}
+
 	h.w.Write(w, r, history)
 }
 
-func (h *Handler) images(w http.ResponseWriter, r *http.Request) {
-	images, err := h.s.ListRepositorySlugs(r.Context())
+func (h *Handler) growth(w http.ResponseWriter, r *http.Request) {
+	registry, slug, err := repository(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	resolution, err := bucketResolution(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	history, err := h.s.FindSnapshots(r.Context(), registry, slug, from, to)
+	if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	if resolution != "" {
+		history = history.Last(resolution)
+	}
+
+	h.w.Write(w, r, history.Growth())
+}
+
+func (h *Handler) leaderboard(w http.ResponseWriter, r *http.Request) {
+	q := scrap.LeaderboardQuery{
+		Registry: registryParam(r),
+		Order:    scrap.LeaderboardOrderAbsolute,
+	}
+
+	var err error
+	if q.Window, err = windowParam(r); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if q.Limit, err = intParam(r, "limit", 50, 500); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if v := r.URL.Query().Get("order"); v != "" {
+		if q.Order, err = scrap.ParseLeaderboardOrder(v); err != nil {
+			h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if q.Order == scrap.LeaderboardOrderRelative {
+		q.MinPulls = 10000
+	}
+	if v := r.URL.Query().Get("min_pulls"); v != "" {
+		if q.MinPulls, err = strconv.ParseInt(v, 10, 64); err != nil {
+			h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter min_pulls must be an integer"))
+			return
+		}
+	}
+
+	leaderboard, err := h.s.Leaderboard(r.Context(), q)
+	if errors.Is(err, scrap.ErrUnknownRegistry) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, scrap.ErrUnknownLeaderboardWindow) {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	h.w.Write(w, r, leaderboard)
+}
+
+func (h *Handler) organization(w http.ResponseWriter, r *http.Request) {
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	resolution, err := bucketResolution(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if resolution == "" {
+		resolution = scrap.ResolutionDay
+	}
+
+	window, err := windowParam(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	org, err := h.s.FindOrganization(r.Context(), registryParam(r), mux.Vars(r)["org"], from, to, resolution, window)
+	if errors.Is(err, scrap.ErrUnknownRegistry) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, scrap.ErrUnknownLeaderboardWindow) {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	h.w.Write(w, r, org)
+}
+
+func (h *Handler) compare(w http.ResponseWriter, r *http.Request) {
+	slugs := slugsParam(r)
+	if len(slugs) == 0 {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, errors.Errorf("query parameter repos is empty"))
+		return
+	}
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	resolution, err := bucketResolution(r)
 	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if resolution == "" {
+		resolution = scrap.ResolutionDay
+	}
+
+	comparison, err := h.s.Compare(r.Context(), registryParam(r), slugs, from, to, resolution)
+	if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, scrap.ErrTooManyRepositories) {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
 		h.w.WriteError(w, r, err)
 		return
 	}
 
-	h.w.Write(w, r, images)
+	h.w.Write(w, r, comparison)
+}
+
+type repositoryPage struct {
+	Repositories []scrap.Repository `json:"repositories"`
+
+	// NextCursor is passed as cursor to fetch the next page and is empty on
+	// the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (h *Handler) images(w http.ResponseWriter, r *http.Request) {
+	q := scrap.RepositoryQuery{
+		Registry: registryParam(r),
+		Prefix:   r.URL.Query().Get("prefix"),
+		Search:   r.URL.Query().Get("q"),
+		Source:   r.URL.Query().Get("source"),
+		Status:   scrap.RepositoryStatusHealthy,
+	}
+
+	var err error
+	if q.Limit, err = intParam(r, "limit", 100, 1000); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		if q.Status, err = scrap.ParseRepositoryStatus(v); err != nil {
+			h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if q.After, err = decodeCursor(r.URL.Query().Get("cursor")); err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	repositories, err := h.s.ListRepositories(r.Context(), q)
+	if errors.Is(err, scrap.ErrUnknownRegistry) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	page := repositoryPage{Repositories: repositories}
+	if len(repositories) == q.Limit {
+		page.NextCursor = encodeCursor(repositories[len(repositories)-1].Slug)
+	}
+
+	h.w.Write(w, r, page)
 }
 
 func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
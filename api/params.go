@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+// repository returns the registry and slug selected by the registry, org and
+// repo query parameters.
+func repository(r *http.Request) (registry, slug string, err error) {
+	org := r.URL.Query().Get("org")
+	repo := r.URL.Query().Get("repo")
+	registry = registryParam(r)
+
+	if org == "" {
+		org = "library"
+	}
+	if repo == "" {
+		return "", "", errors.Errorf("query parameter repo is empty")
+	}
+
+	return registry, fmt.Sprintf("%s/%s", org, repo), nil
+}
+
+// slugsParam returns the comma separated slugs of the repos query parameter.
+// Official images may be given without the "library/" prefix.
+func slugsParam(r *http.Request) []string {
+	var slugs []string
+	for _, v := range r.URL.Query()["repos"] {
+		for _, slug := range strings.Split(v, ",") {
+			if slug = scrap.NormalizeSlug(slug); slug != "" {
+				slugs = append(slugs, slug)
+			}
+		}
+	}
+	return slugs
+}
+
+// timeRange parses the from and to query parameters, which may be RFC 3339
+// timestamps or dates. Missing parameters are returned as zero times.
+func timeRange(r *http.Request) (from, to time.Time, err error) {
+	if from, err = scrap.ParseTime(r.URL.Query().Get("from")); err != nil {
+		return from, to, errors.Wrap(err, "query parameter from is invalid")
+	}
+	if to, err = scrap.ParseTime(r.URL.Query().Get("to")); err != nil {
+		return from, to, errors.Wrap(err, "query parameter to is invalid")
+	}
+	if !to.IsZero() && to.Before(from) {
+		return from, to, errors.Errorf("query parameter to must not be before from")
+	}
+	return from, to, nil
+}
+
+// bucketResolution parses the resolution query parameter, returning an empty
+// resolution if it is not set.
+func bucketResolution(r *http.Request) (scrap.Resolution, error) {
+	v := r.URL.Query().Get("resolution")
+	if v == "" {
+		return "", nil
+	}
+	return scrap.ParseResolution(v)
+}
+
+// intParam parses an integer query parameter, falling back to def if it is not
+// set and clamping it to max.
+func intParam(r *http.Request, name string, def, max int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 1 {
+		return 0, errors.Errorf("query parameter %s must be a positive integer", name)
+	}
+	if i > max {
+		return max, nil
+	}
+	return i, nil
+}
+
+// windowParam parses the window query parameter, which defaults to 7 days and
+// must be one of scrap.LeaderboardWindows.
+func windowParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("window")
+	if v == "" {
+		return 7, nil
+	}
+
+	window, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.WithStack(scrap.ErrUnknownLeaderboardWindow)
+	}
+	return window, scrap.LeaderboardQuery{Window: window}.Validate()
+}
+
+// registryParam returns the registry query parameter, defaulting to Docker Hub.
+func registryParam(r *http.Request) string {
+	if registry := r.URL.Query().Get("registry"); registry != "" {
+		return registry
+	}
+	return scrap.DockerHubRegistry
+}
+
+// encodeCursor turns the slug of the last element of a page into an opaque
+// pagination cursor.
+func encodeCursor(slug string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(slug))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	slug, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.Errorf("query parameter cursor is invalid")
+	}
+	return string(slug), nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+func (h *Handler) tags(w http.ResponseWriter, r *http.Request) {
+	registry, slug, err := repository(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	tags, err := h.s.FindTags(r.Context(), registry, slug)
+	if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return
+	}
+
+	h.w.Write(w, r, tags)
+}
+
+// tagPushes returns the pushes selected by the repository, from, to and tag
+// query parameters together with the resolution, which defaults to week.
+func (h *Handler) tagPushes(w http.ResponseWriter, r *http.Request) (scrap.RepositoryTags, scrap.Resolution, bool) {
+	registry, slug, err := repository(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+
+	resolution, err := bucketResolution(r)
+	if err != nil {
+		h.w.WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return nil, "", false
+	} else if resolution == "" {
+		resolution = scrap.ResolutionWeek
+	}
+
+	pushes, err := h.s.FindTagPushes(r.Context(), registry, slug, from, to)
+	if errors.Is(err, scrap.ErrUnknownRegistry) || errors.Is(err, scrap.ErrRepositoryNotFound) {
+		h.w.WriteErrorCode(w, r, http.StatusNotFound, err)
+		return nil, "", false
+	} else if err != nil {
+		h.w.WriteError(w, r, err)
+		return nil, "", false
+	}
+
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		pushes = pushes.Named(tag)
+	}
+	return pushes, resolution, true
+}
+
+func (h *Handler) tagCadence(w http.ResponseWriter, r *http.Request) {
+	pushes, resolution, ok := h.tagPushes(w, r)
+	if !ok {
+		return
+	}
+
+	h.w.Write(w, r, pushes.Cadence(resolution))
+}
+
+func (h *Handler) tagSizes(w http.ResponseWriter, r *http.Request) {
+	pushes, resolution, ok := h.tagPushes(w, r)
+	if !ok {
+		return
+	}
+
+	h.w.Write(w, r, pushes.Sizes(resolution))
+}
@@ -0,0 +1,201 @@
+// Package chart renders time series of snapshot counts as SVG and PNG images
+// without a browser.
+package chart
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	minWidth  = 200
+	minHeight = 120
+
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 16
+	marginBottom = 32
+
+	dateFormat = "2006-01-02"
+)
+
+// Theme are the colors of a chart.
+type Theme struct {
+	Background color.NRGBA
+	Foreground color.NRGBA
+	Grid       color.NRGBA
+	Line       color.NRGBA
+	Fill       color.NRGBA
+}
+
+var (
+	ThemeLight = Theme{
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Foreground: color.NRGBA{R: 0x55, G: 0x55, B: 0x55, A: 0xff},
+		Grid:       color.NRGBA{R: 0xe5, G: 0xe5, B: 0xe5, A: 0xff},
+		Line:       color.NRGBA{R: 0x1e, G: 0x88, B: 0xe5, A: 0xff},
+		Fill:       color.NRGBA{R: 0x1e, G: 0x88, B: 0xe5, A: 0x33},
+	}
+	ThemeDark = Theme{
+		Background: color.NRGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff},
+		Foreground: color.NRGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff},
+		Grid:       color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff},
+		Line:       color.NRGBA{R: 0x64, G: 0xb5, B: 0xf6, A: 0xff},
+		Fill:       color.NRGBA{R: 0x64, G: 0xb5, B: 0xf6, A: 0x33},
+	}
+)
+
+var ErrUnknownTheme = errors.New("theme must be one of light or dark")
+
+func ParseTheme(s string) (Theme, error) {
+	switch s {
+	case "", "light":
+		return ThemeLight, nil
+	case "dark":
+		return ThemeDark, nil
+	}
+	return Theme{}, errors.WithStack(ErrUnknownTheme)
+}
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Chart is a line chart of chronologically ordered points.
+type Chart struct {
+	Width, Height int
+	Theme         Theme
+	Points        []Point
+}
+
+// tick is a grid line at position with its axis label.
+type tick struct {
+	position int
+	label    string
+}
+
+// layout is the chart projected onto pixels.
+type layout struct {
+	width, height            int
+	left, right, top, bottom int
+	points                   [][2]int
+	xTicks, yTicks           []tick
+}
+
+func (c *Chart) layout() *layout {
+	l := &layout{width: c.Width, height: c.Height}
+	if l.width < minWidth {
+		l.width = minWidth
+	}
+	if l.height < minHeight {
+		l.height = minHeight
+	}
+	l.left, l.right = marginLeft, l.width-marginRight
+	l.top, l.bottom = marginTop, l.height-marginBottom
+
+	if len(c.Points) == 0 {
+		return l
+	}
+
+	start, end := c.Points[0].Time, c.Points[len(c.Points)-1].Time
+	if !end.After(start) {
+		end = start.Add(time.Hour)
+	}
+	x := func(t time.Time) int {
+		return l.left + int(math.Round(float64(l.right-l.left)*float64(t.Sub(start))/float64(end.Sub(start))))
+	}
+
+	lo, hi := c.Points[0].Value, c.Points[0].Value
+	for _, p := range c.Points {
+		lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+	}
+	values := niceTicks(lo, hi, 5)
+	lo, hi = values[0], values[len(values)-1]
+	y := func(v float64) int {
+		return l.bottom - int(math.Round(float64(l.bottom-l.top)*(v-lo)/(hi-lo)))
+	}
+
+	for _, v := range values {
+		l.yTicks = append(l.yTicks, tick{position: y(v), label: FormatCount(int64(v))})
+	}
+
+	n := 4
+	if l.width < 480 {
+		n = 2
+	}
+	for k := 0; k < n; k++ {
+		t := start.Add(time.Duration(float64(end.Sub(start)) * float64(k) / float64(n-1)))
+		l.xTicks = append(l.xTicks, tick{position: x(t), label: t.UTC().Format(dateFormat)})
+	}
+
+	// Keep at most one point per pixel column so that long histories do not
+	// produce huge images.
+	for _, p := range c.Points {
+		px, py := x(p.Time), y(p.Value)
+		if n := len(l.points); n > 0 && l.points[n-1][0] == px {
+			l.points[n-1][1] = py
+			continue
+		}
+		l.points = append(l.points, [2]int{px, py})
+	}
+
+	return l
+}
+
+// niceTicks returns about n evenly spaced round values covering lo to hi.
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	step := niceNumber((hi - lo) / float64(n-1))
+	lo, hi = math.Floor(lo/step)*step, math.Ceil(hi/step)*step
+
+	var ticks []float64
+	for v := lo; v <= hi+step/2; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+func niceNumber(x float64) float64 {
+	exp := math.Pow(10, math.Floor(math.Log10(x)))
+	switch f := x / exp; {
+	case f <= 1:
+		return exp
+	case f <= 2:
+		return 2 * exp
+	case f <= 5:
+		return 5 * exp
+	}
+	return 10 * exp
+}
+
+// FormatCount formats n with a k, M, B or T suffix, keeping one decimal for
+// values below ten, for example 1234 as 1.2k and 123456 as 123k.
+func FormatCount(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+
+	units := []string{"", "k", "M", "B", "T"}
+	v, u := float64(n), 0
+	for v >= 999.5 && u < len(units)-1 {
+		v, u = v/1000, u+1
+	}
+
+	switch {
+	case u == 0:
+		return sign + strconv.FormatInt(n, 10)
+	case v < 9.95:
+		return sign + strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0") + units[u]
+	}
+	return sign + strconv.FormatFloat(math.Round(v), 'f', 0, 64) + units[u]
+}
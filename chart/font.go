@@ -0,0 +1,66 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+	// glyphScale enlarges the glyphs to a readable size.
+	glyphScale = 2
+)
+
+// glyphs is a 3x5 pixel font covering the characters of axis labels. Every
+// row is a bit mask with the leftmost pixel in the highest bit.
+var glyphs = map[rune][glyphHeight]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'.': {0, 0, 0, 0, 2},
+	'-': {0, 0, 7, 0, 0},
+	'+': {0, 2, 7, 2, 0},
+	'/': {1, 1, 2, 4, 4},
+	'k': {4, 5, 6, 5, 5},
+	'M': {5, 7, 7, 5, 5},
+	'B': {6, 5, 6, 5, 6},
+	'T': {7, 2, 2, 2, 2},
+	' ': {0, 0, 0, 0, 0},
+}
+
+// textSize returns the width and height of s in pixels.
+func textSize(s string) (int, int) {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0, 0
+	}
+	return (n*(glyphWidth+1) - 1) * glyphScale, glyphHeight * glyphScale
+}
+
+// drawText draws s with its top left corner at x, y. Unknown characters are
+// left blank.
+func drawText(img draw.Image, x, y int, s string, c color.Color) {
+	src := image.NewUniform(c)
+	for _, r := range s {
+		g := glyphs[r]
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if g[row]&(1<<uint(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px, py := x+col*glyphScale, y+row*glyphScale
+				draw.Draw(img, image.Rect(px, py, px+glyphScale, py+glyphScale), src, image.Point{}, draw.Over)
+			}
+		}
+		x += (glyphWidth + 1) * glyphScale
+	}
+}
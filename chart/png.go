@@ -0,0 +1,83 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// PNG writes the chart as PNG image.
+func (c *Chart) PNG(w io.Writer) error {
+	l := c.layout()
+	img := image.NewNRGBA(image.Rect(0, 0, l.width, l.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c.Theme.Background), image.Point{}, draw.Src)
+
+	grid := image.NewUniform(c.Theme.Grid)
+	for _, t := range l.yTicks {
+		draw.Draw(img, image.Rect(l.left, t.position, l.right, t.position+1), grid, image.Point{}, draw.Over)
+		tw, th := textSize(t.label)
+		drawText(img, l.left-8-tw, t.position-th/2, t.label, c.Theme.Foreground)
+	}
+	for _, t := range l.xTicks {
+		tw, _ := textSize(t.label)
+		x := t.position - tw/2
+		if x+tw > l.width {
+			x = l.width - tw
+		}
+		drawText(img, x, l.bottom+12, t.label, c.Theme.Foreground)
+	}
+
+	fill := image.NewUniform(c.Theme.Fill)
+	for k := 1; k < len(l.points); k++ {
+		a, b := l.points[k-1], l.points[k]
+		for x := a[0]; x < b[0]; x++ {
+			y := a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])
+			draw.Draw(img, image.Rect(x, y, x+1, l.bottom), fill, image.Point{}, draw.Over)
+		}
+	}
+	for k := 1; k < len(l.points); k++ {
+		drawLine(img, l.points[k-1], l.points[k], c.Theme.Line)
+	}
+
+	return errors.WithStack(png.Encode(w, img))
+}
+
+// drawLine draws a two pixel wide line from a to b using Bresenham's
+// algorithm.
+func drawLine(img draw.Image, a, b [2]int, c color.Color) {
+	src := image.NewUniform(c)
+	x0, y0, x1, y1 := a[0], a[1], b[0], b[1]
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	for e := dx + dy; ; {
+		draw.Draw(img, image.Rect(x0, y0-1, x0+1, y0+1), src, image.Point{}, draw.Over)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e, x0 = e+dy, x0+sx
+		}
+		if e2 <= dx {
+			e, y0 = e+dx, y0+sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
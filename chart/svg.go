@@ -0,0 +1,53 @@
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// SVG writes the chart as SVG image.
+func (c *Chart) SVG(w io.Writer) error {
+	l := c.layout()
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[2]d" viewBox="0 0 %[1]d %[2]d">`, l.width, l.height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" %s/>`, l.width, l.height, fill(c.Theme.Background))
+
+	fmt.Fprintf(b, `<g font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11" %s>`, fill(c.Theme.Foreground))
+	for _, t := range l.yTicks {
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%d" y2="%d" %s/>`, l.left, l.right, t.position, t.position, stroke(c.Theme.Grid))
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end" dominant-baseline="middle">%s</text>`, l.left-8, t.position, t.label)
+	}
+	for k, t := range l.xTicks {
+		anchor := "middle"
+		if k == len(l.xTicks)-1 {
+			anchor = "end"
+		}
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="%s">%s</text>`, t.position, l.bottom+20, anchor, t.label)
+	}
+	b.WriteString(`</g>`)
+
+	if len(l.points) > 0 {
+		line := make([]string, len(l.points))
+		for k, p := range l.points {
+			line[k] = fmt.Sprintf("%d,%d", p[0], p[1])
+		}
+		first, last := l.points[0], l.points[len(l.points)-1]
+		fmt.Fprintf(b, `<path d="M%d,%d L%s L%d,%d Z" %s/>`, first[0], l.bottom, strings.Join(line, " L"), last[0], l.bottom, fill(c.Theme.Fill))
+		fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke-width="2" stroke-linejoin="round" %s/>`, strings.Join(line, " "), stroke(c.Theme.Line))
+	}
+
+	b.WriteString(`</svg>`)
+	return b.Flush()
+}
+
+func fill(c color.NRGBA) string {
+	return fmt.Sprintf(`fill="#%02x%02x%02x" fill-opacity="%.2f"`, c.R, c.G, c.B, float64(c.A)/0xff)
+}
+
+func stroke(c color.NRGBA) string {
+	return fmt.Sprintf(`stroke="#%02x%02x%02x" stroke-opacity="%.2f"`, c.R, c.G, c.B, float64(c.A)/0xff)
+}
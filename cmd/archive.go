@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/flagx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+func registerArchiveFlags(cmd *cobra.Command) {
+	cmd.Flags().String("registry", scrap.DockerHubRegistry, "Only include repositories of this registry")
+	cmd.Flags().StringSlice("repos", nil, "Only include these repositories, for example library/nginx,ory/kratos")
+	cmd.Flags().String("prefix", "", "Only include repositories whose slug starts with this prefix, for example ory/")
+	cmd.Flags().String("from", "", "Only include snapshots fetched at or after this RFC 3339 timestamp or date")
+	cmd.Flags().String("to", "", "Only include snapshots fetched at or before this RFC 3339 timestamp or date")
+}
+
+// archiveQuery selects the repositories and snapshots given by the archive
+// flags.
+func archiveQuery(cmd *cobra.Command, l logrus.FieldLogger) scrap.SnapshotQuery {
+	q := scrap.SnapshotQuery{
+		Registry: flagx.MustGetString(cmd, "registry"),
+		Prefix:   flagx.MustGetString(cmd, "prefix"),
+	}
+	for _, slug := range flagx.MustGetStringSlice(cmd, "repos") {
+		if slug = scrap.NormalizeSlug(slug); slug != "" {
+			q.Slugs = append(q.Slugs, slug)
+		}
+	}
+
+	if len(q.Slugs) > 0 && q.Prefix != "" {
+		l.Fatal("Flags --repos and --prefix can not be combined.")
+	}
+
+	var err error
+	if q.From, err = scrap.ParseTime(flagx.MustGetString(cmd, "from")); err != nil {
+		l.WithError(err).Fatal("Flag --from is invalid.")
+	}
+	if q.To, err = scrap.ParseTime(flagx.MustGetString(cmd, "to")); err != nil {
+		l.WithError(err).Fatal("Flag --to is invalid.")
+	}
+	return q
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/logrusx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Exports repositories and their snapshots as gzipped NDJSON archive",
+	Long: `Exports repositories and their snapshots as gzipped NDJSON archive to the given file or, if it is
+omitted or "-", to stdout. The first line of the archive is a manifest recording the format version and
+the filters used.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		q := archiveQuery(cmd, log)
+		store := connect(cmd, log)
+
+		var out io.Writer = os.Stdout
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Create(args[0])
+			if err != nil {
+				log.WithError(err).Fatalf("Unable to create archive: %s", args[0])
+			}
+			defer f.Close()
+			out = f
+		}
+
+		stats, err := scrap.WriteArchive(context.Background(), store, out, q)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to export archive.")
+		}
+		log.
+			WithField("repositories", stats.Repositories).
+			WithField("snapshots", stats.Snapshots).
+			Infof("Export finished")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	registerArchiveFlags(exportCmd)
+}
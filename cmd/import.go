@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/logrusx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Imports an archive written by export",
+	Long: `Imports the repositories and snapshots of an archive written by export from the given file or, if
+it is omitted or "-", from stdin. Repositories and snapshots which already exist are skipped, so the same
+archive can be imported repeatedly.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		q := archiveQuery(cmd, log)
+		store := connect(cmd, log)
+
+		var in io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.WithError(err).Fatalf("Unable to open archive: %s", args[0])
+			}
+			defer f.Close()
+			in = f
+		}
+
+		manifest, stats, err := scrap.ReadArchive(context.Background(), store, in, q)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to import archive.")
+		}
+		log.
+			WithField("version", manifest.Version).
+			WithField("created_at", manifest.CreatedAt).
+			WithField("repositories", stats.Repositories).
+			WithField("snapshots", stats.Snapshots).
+			WithField("skipped", stats.Skipped).
+			Infof("Import finished")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	registerArchiveFlags(importCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// serveMetrics exposes the Prometheus metrics on addr until ctx is canceled.
+func serveMetrics(ctx context.Context, l logrus.FieldLogger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	go func() {
+		l.Infof("Serving metrics on: %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.WithError(err).Errorf("Unable to serve metrics on: %s", addr)
+		}
+	}()
+}
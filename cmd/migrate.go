@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/logrusx"
+
+	"github.com/aeneasr/dockerstats/migrations"
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manages the database schema with the migrations embedded in this binary",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Applies pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		db, driver := migrationDatabase(log)
+		migrateUp(log, db, driver, flagx.MustGetInt(cmd, "limit"))
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Rolls back applied migrations, by default only the last one",
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		db, driver := migrationDatabase(log)
+		source, dialect := migrationSource(log, driver)
+
+		n, err := migrate.ExecMax(db.DB, dialect, source, migrate.Down, flagx.MustGetInt(cmd, "limit"))
+		if err != nil {
+			log.WithError(err).Fatal("Unable to roll back migrations.")
+		}
+		log.Infof("Rolled back %d migrations", n)
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Lists all migrations and when they were applied",
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		db, driver := migrationDatabase(log)
+		source, dialect := migrationSource(log, driver)
+
+		all, err := source.FindMigrations()
+		if err != nil {
+			log.WithError(err).Fatal("Unable to load migrations.")
+		}
+		records, err := migrate.GetMigrationRecords(db.DB, dialect)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to load applied migrations.")
+		}
+
+		applied := make(map[string]*migrate.MigrationRecord, len(records))
+		for _, r := range records {
+			applied[r.Id] = r
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+		for _, m := range all {
+			status := "pending"
+			if r, ok := applied[m.Id]; ok {
+				status = r.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\n", m.Id, status)
+		}
+		_ = w.Flush()
+	},
+}
+
+var migratePullsCmd = &cobra.Command{
+	Use:   "pulls",
+	Short: "Corrects the pull counts of snapshots stored before int32 wraparounds were corrected",
+	Long: `Recomputes the corrected pull count of every snapshot from the raw count reported by Docker Hub,
+walking each repository's history in chronological order. Run it once after upgrading from a version which
+stored raw pull counts. It is safe to run it repeatedly.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		store := connect(cmd, log)
+
+		n, err := scrap.CorrectAllPulls(context.Background(), store, flagx.MustGetString(cmd, "registry"))
+		if err != nil {
+			log.WithError(err).Fatal("Unable to correct pull counts.")
+		}
+		log.Infof("Corrected the pull counts of %d snapshots", n)
+	},
+}
+
+// migrationDatabase opens the database configured by DSN, which must not be
+// the in-memory store.
+func migrationDatabase(l logrus.FieldLogger) (*sqlx.DB, string) {
+	db, driver := openDatabase(l)
+	if db == nil {
+		l.Fatal("The in-memory store does not need migrations.")
+	}
+	return db, driver
+}
+
+func migrationSource(l logrus.FieldLogger, driver string) (migrate.MigrationSource, string) {
+	source, dialect, err := migrations.Source(driver)
+	if err != nil {
+		l.WithError(err).Fatal("Unable to load migrations.")
+	}
+	return source, dialect
+}
+
+// migrateUp applies up to limit pending migrations, all of them if limit is 0.
+func migrateUp(l logrus.FieldLogger, db *sqlx.DB, driver string, limit int) {
+	source, dialect := migrationSource(l, driver)
+	n, err := migrate.ExecMax(db.DB, dialect, source, migrate.Up, limit)
+	if err != nil {
+		l.WithError(err).Fatal("Unable to apply migrations.")
+	}
+	l.Infof("Applied %d migrations", n)
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migratePullsCmd)
+
+	migrateUpCmd.Flags().Int("limit", 0, "Maximum number of migrations to apply (default is all)")
+	migrateDownCmd.Flags().Int("limit", 1, "Maximum number of migrations to roll back, 0 rolls back all")
+	migratePullsCmd.Flags().String("registry", scrap.DockerHubRegistry, "Correct the snapshots of this registry's repositories")
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/logrusx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+var reposCmd = &cobra.Command{
+	Use:   "repos",
+	Short: "Manages the repositories which are scrapped",
+}
+
+var reposAddCmd = &cobra.Command{
+	Use:   "add [slug...]",
+	Short: "Adds repositories so that they are scrapped",
+	Long: `Adds the repositories given as arguments or listed in the file passed to --file. Repositories added
+this way are recorded with source "manual" and are scrapped even when discovery is disabled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+
+		slugs, err := argumentSlugs(flagx.MustGetString(cmd, "file"), args)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to read repositories.")
+		} else if len(slugs) == 0 {
+			log.Fatal("Please pass at least one repository.")
+		}
+
+		store := connect(cmd, log)
+		if err := store.AddRepositories(context.Background(), flagx.MustGetString(cmd, "registry"), scrap.SourceManual, slugs); err != nil {
+			log.WithError(err).Fatal("Unable to add repositories.")
+		}
+		fmt.Printf("Added %d repositories\n", len(slugs))
+	},
+}
+
+var reposRemoveCmd = &cobra.Command{
+	Use:   "remove slug...",
+	Short: "Removes repositories together with their snapshots",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+		store := connect(cmd, log)
+
+		registry := flagx.MustGetString(cmd, "registry")
+		for _, slug := range args {
+			slug = scrap.NormalizeSlug(slug)
+			if err := store.RemoveRepository(context.Background(), registry, slug); errors.Is(err, scrap.ErrRepositoryNotFound) {
+				log.Fatalf("Repository %s does not exist.", slug)
+			} else if err != nil {
+				log.WithError(err).Fatalf("Unable to remove repository %s.", slug)
+			}
+			fmt.Printf("Removed %s\n", slug)
+		}
+	},
+}
+
+var reposListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists repositories ordered by slug",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+
+		status, err := scrap.ParseRepositoryStatus(flagx.MustGetString(cmd, "status"))
+		if err != nil {
+			log.WithError(err).Fatal("Flag --status is invalid.")
+		}
+
+		store := connect(cmd, log)
+		q := scrap.RepositoryQuery{
+			Registry: flagx.MustGetString(cmd, "registry"),
+			Prefix:   flagx.MustGetString(cmd, "prefix"),
+			Source:   flagx.MustGetString(cmd, "source"),
+			Status:   status,
+			Limit:    1000,
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "SLUG\tSOURCE\tSTATUS\tDISCOVERED\tLAST SCRAPPED")
+		for {
+			repositories, err := store.ListRepositories(context.Background(), q)
+			if err != nil {
+				log.WithError(err).Fatal("Unable to list repositories.")
+			}
+
+			for _, r := range repositories {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Slug, r.Source, r.Status(), formatListTime(r.DiscoveredAt), formatListTime(r.LastScrappedAt))
+			}
+			if len(repositories) < q.Limit {
+				break
+			}
+			q.After = repositories[len(repositories)-1].Slug
+		}
+		if err := w.Flush(); err != nil {
+			log.WithError(err).Fatal("Unable to write repositories.")
+		}
+	},
+}
+
+func formatListTime(t time.Time) string {
+	if t.Year() <= 1 {
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func init() {
+	rootCmd.AddCommand(reposCmd)
+	reposCmd.AddCommand(reposAddCmd, reposRemoveCmd, reposListCmd)
+
+	reposCmd.PersistentFlags().String("registry", scrap.DockerHubRegistry, "The registry of the repositories")
+
+	reposAddCmd.Flags().StringP("file", "f", "", `Read repositories from this file, one per line or as YAML list "repositories" ("-" reads stdin)`)
+
+	reposListCmd.Flags().String("prefix", "", "Only list repositories whose slug starts with this prefix, for example ory/")
+	reposListCmd.Flags().String("source", "", "Only list repositories of this source: search, discovery or manual")
+	reposListCmd.Flags().String("status", string(scrap.RepositoryStatusAll), "Only list repositories with this status: all, healthy, retrying or excluded")
+}
@@ -1,14 +1,13 @@
 package cmd
 
 import (
-	"github.com/aeneasr/dockerstats/scrap"
-	"github.com/ory/x/flagx"
 	"github.com/ory/x/logrusx"
 	"runtime"
-	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ory/x/flagx"
 )
 
 // scrapCmd represents the scrap command
@@ -19,7 +18,7 @@ var scrapCmd = &cobra.Command{
 		log := logrusx.New()
 
 		log.Infoln("Connecting to database")
-		db := connect(log)
+		store := connect(cmd, log)
 
 		log.
 			WithField("cpu", runtime.NumCPU()).
@@ -28,35 +27,40 @@ var scrapCmd = &cobra.Command{
 			WithField("goos", runtime.GOOS).
 			Infof("Collected system data")
 
-		ri := scrap.NewScraper(
-			flagx.MustGetInt(cmd, "task-count"),
-			log,
-			db,
-			flagx.MustGetDuration(cmd, "discovery-interval"),
-			flagx.MustGetDuration(cmd, "discovery-delay"),
-			flagx.MustGetDuration(cmd, "snapshot-delay"),
-			flagx.MustGetInt(cmd, "discovery-page-size"),
-			flagx.MustGetInt(cmd, "snapshot-interval"),
-		)
-
-		var wg sync.WaitGroup
-		wg.Add(2)
-		log.Infoln("Starting scrapers")
-		go ri.Discover()
-		go ri.Scrap()
+		ri := newScraper(cmd, log, store)
 
-		wg.Wait()
+		ctx, cancel := signalContext(log)
+		defer cancel()
+
+		seedWatchlist(ctx, cmd, log, store)
+
+		if addr := flagx.MustGetString(cmd, "metrics-address"); addr != "" {
+			serveMetrics(ctx, log, addr)
+		}
+
+		log.Infoln("Starting scrapers")
+		if err := ri.Run(ctx); err != nil {
+			log.WithError(err).Fatal("Scrapers stopped with errors")
+		}
+		log.Infoln("Scrapers stopped")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(scrapCmd)
 
-	scrapCmd.Flags().IntP("task-count", "n", 3, "Number of concurrent snapshot tasks")
+	// Persistent flags are shared with "scrap once".
+	scrapCmd.PersistentFlags().Bool("auto-migrate", false, "Apply pending database migrations on start")
+	scrapCmd.PersistentFlags().IntP("task-count", "n", 3, "Number of concurrent snapshot tasks")
 
-	scrapCmd.Flags().Int("snapshot-interval", 1, "Run the snapshot task every X days")
-	scrapCmd.Flags().Duration("discovery-interval", time.Hour*24*5, "Run the discovery task every interval")
-	scrapCmd.Flags().Duration("discovery-delay", time.Second*30, "Number of concurrent snapshot tasks")
-	scrapCmd.Flags().Int("discovery-page-size", 500, "Number of elements to traverse during discovery")
-	scrapCmd.Flags().Duration("snapshot-delay", time.Second*30, "Number of concurrent snapshot tasks")
+	scrapCmd.PersistentFlags().Int("snapshot-interval", 1, "Run the snapshot task every X days")
+	scrapCmd.PersistentFlags().Duration("discovery-interval", time.Hour*24*5, "Run the discovery task every interval, 0 disables crawling")
+	scrapCmd.PersistentFlags().Duration("discovery-delay", time.Second*30, "Wait this long between two discovery result pages")
+	scrapCmd.PersistentFlags().Int("discovery-page-size", 500, "Number of elements to traverse during discovery")
+	scrapCmd.PersistentFlags().Int("tag-pages", 0, "Number of pages of 100 most recently pushed tags to fetch with every snapshot, 0 disables tags")
+	scrapCmd.PersistentFlags().Duration("snapshot-delay", time.Second*30, "Wait this long before polling for due repositories again")
+	scrapCmd.PersistentFlags().Duration("lease-duration", time.Hour, "How long a scraper may hold repositories before other instances take them over")
+	scrapCmd.PersistentFlags().String("instance-id", "", "Identifies this instance when leasing repositories (default is hostname and pid)")
+	scrapCmd.Flags().String("watchlist", "", "Add the repositories listed in this text or YAML file before scrapping")
+	scrapCmd.Flags().String("metrics-address", "", "Serve Prometheus metrics at /metrics on this address, for example :9090")
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/logrusx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+var scrapOnceCmd = &cobra.Command{
+	Use:   "once [slug...]",
+	Short: "Fetches one snapshot of all due or the given repositories and exits",
+	Long: `Fetches one snapshot of every repository which is due or, if slugs are given as arguments or in
+the file passed to --file, of these repositories. It prints a summary and exits with a non-zero code if
+any snapshot could not be fetched, which makes it suitable for cron jobs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := logrusx.New()
+
+		slugs, err := argumentSlugs(flagx.MustGetString(cmd, "file"), args)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to read repositories.")
+		}
+
+		store := connect(cmd, log)
+		ri := newScraper(cmd, log, store)
+
+		ctx, cancel := signalContext(log)
+		defer cancel()
+
+		summary, err := ri.ScrapOnce(ctx, scrap.DockerHubRegistry, slugs)
+		if summary != nil {
+			fmt.Printf("Fetched %d snapshots, %d failed\n", summary.Succeeded, summary.Failed)
+			failed := make([]string, 0, len(summary.Failures))
+			for slug := range summary.Failures {
+				failed = append(failed, slug)
+			}
+			sort.Strings(failed)
+			for _, slug := range failed {
+				fmt.Printf("  %s: %s\n", slug, summary.Failures[slug])
+			}
+		}
+		if err != nil {
+			log.WithError(err).Fatal("Scrapping stopped with errors")
+		}
+		if summary.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	scrapCmd.AddCommand(scrapOnceCmd)
+
+	scrapOnceCmd.Flags().StringP("file", "f", "", `Read repositories from this file, one per line or as YAML list "repositories" ("-" reads stdin)`)
+}
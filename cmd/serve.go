@@ -30,13 +30,25 @@ import (
 	"github.com/aeneasr/dockerstats/scrap"
 )
 
-func connect(l logrus.FieldLogger) *sqlx.DB {
+// openDatabase opens the SQL database configured by DSN and returns it with
+// its driver name. Besides PostgreSQL DSNs, it accepts "sqlite://<path>". It
+// returns a nil database if DSN is "memory".
+func openDatabase(l logrus.FieldLogger) (*sqlx.DB, string) {
 	dsn := os.Getenv("DSN")
 	if dsn == "" {
 		l.Fatalf("DSN is empty.")
 	}
 
-	db, err := sqlx.Open("pgx", dsn)
+	if dsn == "memory" {
+		return nil, ""
+	}
+
+	driver := "pgx"
+	if strings.HasPrefix(dsn, "sqlite://") {
+		driver, dsn = "sqlite3", strings.TrimPrefix(dsn, "sqlite://")
+	}
+
+	db, err := sqlx.Open(driver, dsn)
 	if err != nil {
 		l.WithError(err).Fatal("Unable to connect to database.")
 	}
@@ -45,12 +57,75 @@ func connect(l logrus.FieldLogger) *sqlx.DB {
 	db.DB.SetMaxIdleConns(2)
 	db.DB.SetMaxOpenConns(20)
 	db.DB.SetConnMaxLifetime(0)
+	if driver == "sqlite3" {
+		// SQLite only supports a single writer.
+		db.DB.SetMaxOpenConns(1)
+	}
 
 	if err := db.Ping(); err != nil {
 		l.WithError(err).Fatal("Unable to connect to database.")
 	}
+	return db, driver
+}
+
+// connect opens the store configured by DSN, which may also be "memory". If
+// the command has --auto-migrate set, pending migrations are applied first.
+func connect(cmd *cobra.Command, l logrus.FieldLogger) scrap.Store {
+	db, driver := openDatabase(l)
+	if db == nil {
+		l.Warnln("Using the in-memory store, all data will be lost on shutdown.")
+		return scrap.NewMemoryStore()
+	}
+
+	if autoMigrate, _ := cmd.Flags().GetBool("auto-migrate"); autoMigrate {
+		migrateUp(l, db, driver, 0)
+	}
+
+	if driver == "sqlite3" {
+		return scrap.NewSQLiteStore(db)
+	}
+	return scrap.NewPostgresStore(db)
+}
+
+func dockerHubCredentials(l logrus.FieldLogger) []scrap.DockerHubCredentials {
+	credentials, err := scrap.ParseDockerHubCredentials(viper.GetStringSlice("docker_hub.credentials"))
+	if err != nil {
+		l.WithError(err).Fatal("Unable to parse Docker Hub credentials.")
+	}
+	return credentials
+}
+
+// instanceID identifies this process when leasing repositories.
+func instanceID(cmd *cobra.Command, l logrus.FieldLogger) string {
+	if id := flagx.MustGetString(cmd, "instance-id"); id != "" {
+		return id
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		l.WithError(err).Fatal("Unable to determine hostname, please set --instance-id.")
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
 
-	return db
+func newScraper(cmd *cobra.Command, l logrus.FieldLogger, store scrap.Store) *scrap.Scraper {
+	return scrap.NewScraper(
+		flagx.MustGetInt(cmd, "task-count"),
+		l,
+		store,
+		flagx.MustGetDuration(cmd, "discovery-interval"),
+		flagx.MustGetDuration(cmd, "snapshot-delay"),
+		flagx.MustGetInt(cmd, "snapshot-interval"),
+		instanceID(cmd, l),
+		flagx.MustGetDuration(cmd, "lease-duration"),
+		scrap.NewDockerHub(
+			l,
+			flagx.MustGetInt(cmd, "discovery-page-size"),
+			flagx.MustGetDuration(cmd, "discovery-delay"),
+			flagx.MustGetInt(cmd, "tag-pages"),
+			dockerHubCredentials(l)...,
+		),
+	)
 }
 
 var serveCmd = &cobra.Command{
@@ -59,7 +134,7 @@ var serveCmd = &cobra.Command{
 		log := logrusx.New()
 
 		log.Infoln("Connecting to database")
-		db := connect(log)
+		store := connect(cmd, log)
 
 		log.
 			WithField("cpu", runtime.NumCPU()).
@@ -68,16 +143,7 @@ var serveCmd = &cobra.Command{
 			WithField("goos", runtime.GOOS).
 			Infof("Collected system data")
 
-		ri := scrap.NewScraper(
-			flagx.MustGetInt(cmd, "task-count"),
-			log,
-			db,
-			flagx.MustGetDuration(cmd, "discovery-interval"),
-			flagx.MustGetDuration(cmd, "discovery-delay"),
-			flagx.MustGetDuration(cmd, "snapshot-delay"),
-			flagx.MustGetInt(cmd, "discovery-page-size"),
-			flagx.MustGetInt(cmd, "snapshot-interval"),
-		)
+		ri := newScraper(cmd, log, store)
 		writer := herodot.NewJSONWriter(log)
 		router := mux.NewRouter()
 		api.NewHandler(ri, writer).Handle(router)
@@ -127,21 +193,70 @@ var serveCmd = &cobra.Command{
 			Handler: c.Handler(mw),
 		})
 
+		ctx, cancel := signalContext(log)
+		defer cancel()
+
+		if addr := flagx.MustGetString(cmd, "metrics-address"); addr != "" {
+			serveMetrics(ctx, log, addr)
+		}
+
+		seedWatchlist(ctx, cmd, log, store)
+
+		scrapped := make(chan error, 1)
+		if flagx.MustGetBool(cmd, "scrap") {
+			log.Infoln("Starting scrapers")
+			go func() {
+				scrapped <- ri.Run(ctx)
+			}()
+		} else {
+			close(scrapped)
+		}
+
+		var watchlist []string
+		for _, slug := range flagx.MustGetStringSlice(cmd, "export-repositories") {
+			if slug = scrap.NormalizeSlug(slug); slug != "" {
+				watchlist = append(watchlist, slug)
+			}
+		}
+		if len(watchlist) > 0 {
+			if flagx.MustGetString(cmd, "metrics-address") == "" {
+				log.Warnln("Exported repository metrics are only served when --metrics-address is set.")
+			}
+			log.Infof("Exporting metrics of %d repositories", len(watchlist))
+			go func() {
+				_ = ri.Export(ctx, scrap.DockerHubRegistry, watchlist, flagx.MustGetDuration(cmd, "export-interval"))
+			}()
+		}
+
 		log.Infof("Listening on: %s", addr)
 		if err := graceful.Graceful(server.ListenAndServe, server.Shutdown); err != nil {
 			log.WithError(err).Fatalf("Unable to listen on: %s", addr)
 		}
+
+		cancel()
+		if err := <-scrapped; err != nil {
+			log.WithError(err).Fatal("Scrapers stopped with errors")
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
+	serveCmd.Flags().Bool("scrap", false, "Run the scrapers in the same process")
+	serveCmd.Flags().Bool("auto-migrate", false, "Apply pending database migrations on start")
 	serveCmd.Flags().IntP("task-count", "n", 3, "Number of concurrent snapshot tasks")
 
 	serveCmd.Flags().Int("snapshot-interval", 1, "Run the snapshot task every X days")
-	serveCmd.Flags().Duration("discovery-interval", time.Hour*24*5, "Run the discovery task every interval")
-	serveCmd.Flags().Duration("discovery-delay", time.Second*30, "Number of concurrent snapshot tasks")
+	serveCmd.Flags().Duration("discovery-interval", time.Hour*24*5, "Run the discovery task every interval, 0 disables crawling")
+	serveCmd.Flags().Duration("discovery-delay", time.Second*30, "Wait this long between two discovery result pages")
 	serveCmd.Flags().Int("discovery-page-size", 500, "Number of elements to traverse during discovery")
-	serveCmd.Flags().Duration("snapshot-delay", time.Second*30, "Number of concurrent snapshot tasks")
+	serveCmd.Flags().Int("tag-pages", 0, "Number of pages of 100 most recently pushed tags to fetch with every snapshot, 0 disables tags")
+	serveCmd.Flags().Duration("snapshot-delay", time.Second*30, "Wait this long before polling for due repositories again")
+	serveCmd.Flags().Duration("lease-duration", time.Hour, "How long a scraper may hold repositories before other instances take them over")
+	serveCmd.Flags().String("watchlist", "", "Add the repositories listed in this text or YAML file on start")
+	serveCmd.Flags().String("metrics-address", "", "Serve Prometheus metrics at /metrics on this address, for example :9090")
+	serveCmd.Flags().StringSlice("export-repositories", nil, "Expose the latest pull and star counts of these repositories at /metrics, for example library/nginx,ory/kratos")
+	serveCmd.Flags().Duration("export-interval", time.Minute, "How often the exported repository metrics are refreshed from the database")
+	serveCmd.Flags().String("instance-id", "", "Identifies this instance when leasing repositories (default is hostname and pid)")
 }
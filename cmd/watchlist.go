@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ory/x/flagx"
+
+	"github.com/aeneasr/dockerstats/scrap"
+)
+
+// readWatchlist reads the repositories listed in file. YAML files list them
+// under the "repositories" key, all other files contain one repository per
+// line where empty lines and lines starting with # are ignored. "-" reads
+// stdin.
+func readWatchlist(file string) ([]string, error) {
+	var slugs []string
+	if ext := filepath.Ext(file); ext == ".yaml" || ext == ".yml" {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, slug := range v.GetStringSlice("repositories") {
+			if slug = scrap.NormalizeSlug(slug); slug != "" {
+				slugs = append(slugs, slug)
+			}
+		}
+		return slugs, nil
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		slugs = append(slugs, scrap.NormalizeSlug(line))
+	}
+	return slugs, errors.WithStack(s.Err())
+}
+
+// argumentSlugs returns the slugs given as arguments followed by the ones
+// listed in file, if set.
+func argumentSlugs(file string, args []string) ([]string, error) {
+	var slugs []string
+	for _, slug := range args {
+		if slug = scrap.NormalizeSlug(slug); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	if file == "" {
+		return slugs, nil
+	}
+
+	listed, err := readWatchlist(file)
+	if err != nil {
+		return nil, err
+	}
+	return append(slugs, listed...), nil
+}
+
+// seedWatchlist adds the repositories of the file passed to --watchlist as
+// manual repositories.
+func seedWatchlist(ctx context.Context, cmd *cobra.Command, l logrus.FieldLogger, store scrap.Store) {
+	file := flagx.MustGetString(cmd, "watchlist")
+	if file == "" {
+		return
+	}
+
+	slugs, err := readWatchlist(file)
+	if err != nil {
+		l.WithError(err).Fatal("Unable to read watchlist.")
+	}
+	if err := store.AddRepositories(ctx, scrap.DockerHubRegistry, scrap.SourceManual, slugs); err != nil {
+		l.WithError(err).Fatal("Unable to add watched repositories.")
+	}
+	l.Infof("Added %d repositories from watchlist", len(slugs))
+}
@@ -0,0 +1,28 @@
+// Package migrations embeds the SQL schema migrations of all supported
+// databases into the binary.
+package migrations
+
+import (
+	"github.com/gobuffalo/packr"
+	"github.com/pkg/errors"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var ErrUnsupportedDriver = errors.New("database driver does not support migrations")
+
+var (
+	postgres = packr.NewBox("./postgres")
+	sqlite   = packr.NewBox("./sqlite")
+)
+
+// Source returns the migrations of the database driver and the sql-migrate
+// dialect to apply them with.
+func Source(driver string) (migrate.MigrationSource, string, error) {
+	switch driver {
+	case "pgx":
+		return &migrate.PackrMigrationSource{Box: postgres}, "postgres", nil
+	case "sqlite3":
+		return &migrate.PackrMigrationSource{Box: sqlite}, "sqlite3", nil
+	}
+	return nil, "", errors.Wrapf(ErrUnsupportedDriver, "driver: %s", driver)
+}
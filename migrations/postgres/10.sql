@@ -0,0 +1,9 @@
+-- +migrate Up notransaction
+ALTER TYPE source ADD VALUE IF NOT EXISTS 'manual';
+
+-- +migrate Down
+UPDATE repositories SET source='search' WHERE source='manual';
+ALTER TYPE source RENAME TO source_old;
+CREATE TYPE source AS ENUM ('search', 'discovery');
+ALTER TABLE repositories ALTER COLUMN source TYPE source USING source::text::source;
+DROP TYPE source_old;
@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE repositories ADD COLUMN registry VARCHAR(64) NOT NULL DEFAULT 'docker';
+ALTER TABLE repositories DROP CONSTRAINT repositories_slug_key;
+ALTER TABLE repositories ADD CONSTRAINT repositories_registry_slug_key UNIQUE (registry, slug);
+
+-- +migrate Down
+ALTER TABLE repositories DROP CONSTRAINT repositories_registry_slug_key;
+ALTER TABLE repositories ADD CONSTRAINT repositories_slug_key UNIQUE (slug);
+ALTER TABLE repositories DROP COLUMN registry;
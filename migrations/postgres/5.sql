@@ -0,0 +1,5 @@
+-- +migrate Up
+-- Rate limited and failed upstream requests used to exclude repositories permanently.
+UPDATE repositories SET error_code=0 WHERE error_code=429 OR error_code>=500;
+
+-- +migrate Down
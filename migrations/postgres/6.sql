@@ -0,0 +1,17 @@
+-- +migrate Up
+ALTER TABLE repositories ADD COLUMN error_kind VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE repositories ADD COLUMN retry_count INT NOT NULL DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN retry_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+
+UPDATE repositories SET error_kind='gone' WHERE error_code IN (404, 410);
+UPDATE repositories SET error_kind='private' WHERE error_code IN (401, 403);
+UPDATE repositories SET error_kind='unexpected' WHERE error_code<>0 AND error_kind='';
+
+CREATE INDEX repositories_retry_at_idx ON repositories (retry_at);
+
+-- +migrate Down
+DROP INDEX repositories_retry_at_idx;
+
+ALTER TABLE repositories DROP COLUMN retry_at;
+ALTER TABLE repositories DROP COLUMN retry_count;
+ALTER TABLE repositories DROP COLUMN error_kind;
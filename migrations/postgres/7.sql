@@ -0,0 +1,11 @@
+-- +migrate Up
+ALTER TABLE repositories ADD COLUMN leased_until TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE repositories ADD COLUMN leased_by VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX repositories_leased_until_idx ON repositories (leased_until);
+
+-- +migrate Down
+DROP INDEX repositories_leased_until_idx;
+
+ALTER TABLE repositories DROP COLUMN leased_by;
+ALTER TABLE repositories DROP COLUMN leased_until;
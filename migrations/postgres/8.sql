@@ -0,0 +1,11 @@
+-- +migrate Up
+ALTER TABLE repository_snapshots ALTER COLUMN pulls TYPE BIGINT;
+ALTER TABLE repository_snapshots ALTER COLUMN stars TYPE BIGINT;
+ALTER TABLE repository_snapshots ADD COLUMN pulls_raw BIGINT NOT NULL DEFAULT 0;
+
+UPDATE repository_snapshots SET pulls_raw=pulls;
+
+-- +migrate Down
+ALTER TABLE repository_snapshots DROP COLUMN pulls_raw;
+ALTER TABLE repository_snapshots ALTER COLUMN stars TYPE INT;
+ALTER TABLE repository_snapshots ALTER COLUMN pulls TYPE INT;
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE repository_growth
+(
+    repository_id         INT              NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+    window_days           SMALLINT         NOT NULL,
+    pulls                 BIGINT           NOT NULL,
+    pulls_growth          BIGINT           NOT NULL,
+    pulls_growth_relative DOUBLE PRECISION NOT NULL,
+    stars_growth          BIGINT           NOT NULL,
+    computed_at           TIMESTAMP        NOT NULL,
+    PRIMARY KEY (repository_id, window_days)
+);
+
+CREATE INDEX repository_growth_absolute_idx ON repository_growth (window_days, pulls_growth DESC);
+CREATE INDEX repository_growth_relative_idx ON repository_growth (window_days, pulls_growth_relative DESC);
+
+-- +migrate Down
+DROP TABLE repository_growth;
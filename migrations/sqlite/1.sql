@@ -0,0 +1,38 @@
+-- +migrate Up
+CREATE TABLE repositories
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    registry         VARCHAR(64)  NOT NULL DEFAULT 'docker',
+    slug             VARCHAR(255) NOT NULL,
+    discovered_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    source           VARCHAR(16)  NOT NULL CHECK (source IN ('search', 'discovery')),
+    last_scrapped_at TIMESTAMP    NOT NULL,
+    error_code       SMALLINT     NOT NULL DEFAULT 0,
+    error_kind       VARCHAR(16)  NOT NULL DEFAULT '',
+    error_at         TIMESTAMP    NOT NULL,
+    retry_count      INTEGER      NOT NULL DEFAULT 0,
+    retry_at         TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_until     TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_by        VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE (registry, slug)
+);
+
+CREATE TABLE repository_snapshots
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER   NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+    fetched_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pulls         INTEGER   NOT NULL DEFAULT 0,
+    stars         INTEGER   NOT NULL DEFAULT 0
+);
+
+CREATE INDEX repositories_snapshots_fetched_at_idx ON repository_snapshots (fetched_at);
+CREATE INDEX repository_snapshots_repository_id_idx ON repository_snapshots (repository_id);
+CREATE INDEX repositories_scrapping_order_index_idx ON repositories (last_scrapped_at, id);
+CREATE INDEX repositories_error_code_idx ON repositories (error_code);
+CREATE INDEX repositories_retry_at_idx ON repositories (retry_at);
+CREATE INDEX repositories_leased_until_idx ON repositories (leased_until);
+
+-- +migrate Down
+DROP TABLE repository_snapshots;
+DROP TABLE repositories;
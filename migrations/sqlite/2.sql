@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE repository_snapshots ADD COLUMN pulls_raw INTEGER NOT NULL DEFAULT 0;
+
+UPDATE repository_snapshots SET pulls_raw=pulls;
+
+-- +migrate Down
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE repository_growth
+(
+    repository_id         INTEGER   NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+    window_days           SMALLINT  NOT NULL,
+    pulls                 INTEGER   NOT NULL,
+    pulls_growth          INTEGER   NOT NULL,
+    pulls_growth_relative REAL      NOT NULL,
+    stars_growth          INTEGER   NOT NULL,
+    computed_at           TIMESTAMP NOT NULL,
+    PRIMARY KEY (repository_id, window_days)
+);
+
+CREATE INDEX repository_growth_absolute_idx ON repository_growth (window_days, pulls_growth DESC);
+CREATE INDEX repository_growth_relative_idx ON repository_growth (window_days, pulls_growth_relative DESC);
+
+-- +migrate Down
+DROP TABLE repository_growth;
@@ -0,0 +1,58 @@
+-- +migrate Up
+-- SQLite can not alter CHECK constraints, so the table is rebuilt. This relies on foreign keys being disabled,
+-- which is the default, as dropping the table would otherwise delete all snapshots.
+CREATE TABLE repositories_new
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    registry         VARCHAR(64)  NOT NULL DEFAULT 'docker',
+    slug             VARCHAR(255) NOT NULL,
+    discovered_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    source           VARCHAR(16)  NOT NULL CHECK (source IN ('search', 'discovery', 'manual')),
+    last_scrapped_at TIMESTAMP    NOT NULL,
+    error_code       SMALLINT     NOT NULL DEFAULT 0,
+    error_kind       VARCHAR(16)  NOT NULL DEFAULT '',
+    error_at         TIMESTAMP    NOT NULL,
+    retry_count      INTEGER      NOT NULL DEFAULT 0,
+    retry_at         TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_until     TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_by        VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE (registry, slug)
+);
+
+INSERT INTO repositories_new SELECT * FROM repositories;
+DROP TABLE repositories;
+ALTER TABLE repositories_new RENAME TO repositories;
+
+CREATE INDEX repositories_scrapping_order_index_idx ON repositories (last_scrapped_at, id);
+CREATE INDEX repositories_error_code_idx ON repositories (error_code);
+CREATE INDEX repositories_retry_at_idx ON repositories (retry_at);
+CREATE INDEX repositories_leased_until_idx ON repositories (leased_until);
+
+-- +migrate Down
+CREATE TABLE repositories_old
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    registry         VARCHAR(64)  NOT NULL DEFAULT 'docker',
+    slug             VARCHAR(255) NOT NULL,
+    discovered_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    source           VARCHAR(16)  NOT NULL CHECK (source IN ('search', 'discovery')),
+    last_scrapped_at TIMESTAMP    NOT NULL,
+    error_code       SMALLINT     NOT NULL DEFAULT 0,
+    error_kind       VARCHAR(16)  NOT NULL DEFAULT '',
+    error_at         TIMESTAMP    NOT NULL,
+    retry_count      INTEGER      NOT NULL DEFAULT 0,
+    retry_at         TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_until     TIMESTAMP    NOT NULL DEFAULT '0001-01-01 00:00:00',
+    leased_by        VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE (registry, slug)
+);
+
+UPDATE repositories SET source='search' WHERE source='manual';
+INSERT INTO repositories_old SELECT * FROM repositories;
+DROP TABLE repositories;
+ALTER TABLE repositories_old RENAME TO repositories;
+
+CREATE INDEX repositories_scrapping_order_index_idx ON repositories (last_scrapped_at, id);
+CREATE INDEX repositories_error_code_idx ON repositories (error_code);
+CREATE INDEX repositories_retry_at_idx ON repositories (retry_at);
+CREATE INDEX repositories_leased_until_idx ON repositories (leased_until);
@@ -0,0 +1,30 @@
+-- +migrate Up
+CREATE TABLE repository_tags
+(
+    repository_id INTEGER   NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+    name          TEXT      NOT NULL,
+    digest        TEXT      NOT NULL,
+    architectures TEXT      NOT NULL,
+    size          INTEGER   NOT NULL,
+    last_pushed   TIMESTAMP NOT NULL,
+    fetched_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (repository_id, name)
+);
+
+CREATE TABLE repository_tag_pushes
+(
+    repository_id INTEGER   NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+    name          TEXT      NOT NULL,
+    digest        TEXT      NOT NULL,
+    architectures TEXT      NOT NULL,
+    size          INTEGER   NOT NULL,
+    last_pushed   TIMESTAMP NOT NULL,
+    fetched_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (repository_id, name, digest)
+);
+
+CREATE INDEX repository_tag_pushes_last_pushed_idx ON repository_tag_pushes (repository_id, last_pushed);
+
+-- +migrate Down
+DROP TABLE repository_tag_pushes;
+DROP TABLE repository_tags;
@@ -0,0 +1,44 @@
+package scrap
+
+import "time"
+
+// AlignBuckets places several bucket series of the same resolution onto a
+// common grid reaching from the earliest to the latest bucket of any series.
+// The returned series hold nil where a series has no bucket.
+func AlignBuckets(r Resolution, series ...SnapshotBuckets) ([]time.Time, [][]*SnapshotBucket) {
+	var first, last time.Time
+	for _, s := range series {
+		if len(s) == 0 {
+			continue
+		}
+		if first.IsZero() || s[0].Timestamp.Before(first) {
+			first = s[0].Timestamp
+		}
+		if s[len(s)-1].Timestamp.After(last) {
+			last = s[len(s)-1].Timestamp
+		}
+	}
+
+	var grid []time.Time
+	if !first.IsZero() {
+		for t := first; !t.After(last); t = r.Next(t) {
+			grid = append(grid, t)
+		}
+	}
+
+	aligned := make([][]*SnapshotBucket, len(series))
+	for k, s := range series {
+		aligned[k] = make([]*SnapshotBucket, len(grid))
+		var j int
+		for g, t := range grid {
+			for j < len(s) && s[j].Timestamp.Before(t) {
+				j++
+			}
+			if j < len(s) && s[j].Timestamp.Equal(t) {
+				aligned[k][g] = &s[j]
+			}
+		}
+	}
+
+	return grid, aligned
+}
@@ -0,0 +1,225 @@
+package scrap
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveVersion is the version of the archive format written by WriteArchive.
+// Version 2 added the error and retry state of repositories. Archives of
+// version 1 only restore when repositories were last scrapped.
+const ArchiveVersion = 2
+
+// archiveBatchSize is the number of snapshots imported per transaction.
+const archiveBatchSize = 500
+
+var ErrUnsupportedArchive = errors.New("archive version is not supported")
+
+// ArchiveManifest is the first line of an archive and records how it was
+// created.
+type ArchiveManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Registry  string    `json:"registry"`
+	Slugs     []string  `json:"slugs,omitempty"`
+	Prefix    string    `json:"prefix,omitempty"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// archiveEntry is one of the lines following the manifest. Every repository
+// is followed by its snapshots.
+type archiveEntry struct {
+	Repository *archiveRepository  `json:"repository,omitempty"`
+	Snapshot   *RepositorySnapshot `json:"snapshot,omitempty"`
+}
+
+// archiveRepository adds the state which the API does not expose to the
+// repository so that it is restored on import.
+type archiveRepository struct {
+	*Repository
+	ErrorCode  int       `json:"error_code"`
+	ErrorAt    time.Time `json:"error_at"`
+	RetryCount int       `json:"retry_count"`
+	RetryAt    time.Time `json:"retry_at"`
+}
+
+// ArchiveStats counts the contents of a written or read archive.
+type ArchiveStats struct {
+	Repositories int `json:"repositories"`
+	Snapshots    int `json:"snapshots"`
+	// Skipped counts imported snapshots which were already stored.
+	Skipped int `json:"skipped"`
+}
+
+// WriteArchive writes the repositories and snapshots selected by q as gzipped
+// NDJSON to w.
+func WriteArchive(ctx context.Context, s Store, w io.Writer, q SnapshotQuery) (*ArchiveStats, error) {
+	if q.To.IsZero() {
+		q.To = maxDate
+	}
+
+	gz := gzip.NewWriter(w)
+	e := json.NewEncoder(gz)
+	if err := e.Encode(&ArchiveManifest{
+		Version:   ArchiveVersion,
+		CreatedAt: time.Now().UTC(),
+		Registry:  q.Registry,
+		Slugs:     q.Slugs,
+		Prefix:    q.Prefix,
+		From:      q.From,
+		To:        q.To,
+	}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Slugs take precedence over the prefix like in SnapshotQuery, which also
+	// selects the repositories on import.
+	prefix := q.Prefix
+	if len(q.Slugs) > 0 {
+		prefix = ""
+	}
+
+	var stats ArchiveStats
+	for after := ""; ; {
+		repositories, err := s.ListRepositories(ctx, RepositoryQuery{
+			Registry: q.Registry,
+			Prefix:   prefix,
+			Slugs:    q.Slugs,
+			Status:   RepositoryStatusAll,
+			After:    after,
+			Limit:    1000,
+		})
+		if err != nil {
+			return nil, err
+		} else if len(repositories) == 0 {
+			break
+		}
+
+		for k := range repositories {
+			r := &repositories[k]
+			if err := e.Encode(&archiveEntry{Repository: &archiveRepository{
+				Repository: r,
+				ErrorCode:  r.ErrorCode,
+				ErrorAt:    r.ErrorAt,
+				RetryCount: r.RetryCount,
+				RetryAt:    r.RetryAt,
+			}}); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			stats.Repositories++
+
+			snapshots, err := s.ListSnapshots(ctx, r.Registry, r.Slug, q.From, q.To)
+			if err != nil {
+				return nil, err
+			}
+			for _, snapshot := range snapshots {
+				if err := e.Encode(&archiveEntry{Snapshot: snapshot}); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				stats.Snapshots++
+			}
+		}
+		after = repositories[len(repositories)-1].Slug
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &stats, nil
+}
+
+// ReadArchive imports the repositories and snapshots of an archive written by
+// WriteArchive which are selected by q. Snapshots which are already stored are
+// skipped and the state of repositories is only restored if it is newer than
+// the stored one, so archives can be imported repeatedly.
+func ReadArchive(ctx context.Context, s Store, r io.Reader, q SnapshotQuery) (*ArchiveManifest, *ArchiveStats, error) {
+	if q.To.IsZero() {
+		q.To = maxDate
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer gz.Close()
+
+	d := json.NewDecoder(gz)
+	var manifest ArchiveManifest
+	if err := d.Decode(&manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to decode archive manifest")
+	}
+	if manifest.Version < 1 || manifest.Version > ArchiveVersion {
+		return nil, nil, errors.Wrapf(ErrUnsupportedArchive, "version: %d", manifest.Version)
+	}
+
+	var stats ArchiveStats
+	var current *archiveRepository
+	var pending RepositorySnapshots
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		imported, err := s.ImportSnapshots(ctx, current.Registry, current.Slug, pending)
+		if err != nil {
+			return err
+		}
+		stats.Snapshots += imported
+		stats.Skipped += len(pending) - imported
+		pending = pending[:0]
+		return nil
+	}
+
+	for {
+		var entry archiveEntry
+		if err := d.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to decode archive entry")
+		}
+
+		switch {
+		case entry.Repository != nil:
+			if err := flush(); err != nil {
+				return nil, nil, err
+			}
+
+			current = nil
+			r := entry.Repository
+			if r.Repository == nil || !q.matches(r.Registry, r.Slug) {
+				continue
+			}
+			r.Repository.ErrorCode, r.Repository.ErrorAt = r.ErrorCode, r.ErrorAt
+			r.Repository.RetryCount, r.Repository.RetryAt = r.RetryCount, r.RetryAt
+			if err := s.ImportRepository(ctx, r.Repository, manifest.Version >= 2); err != nil {
+				return nil, nil, err
+			}
+			current = r
+			stats.Repositories++
+		case entry.Snapshot != nil:
+			if current == nil || entry.Snapshot.Timestamp.Before(q.From) || entry.Snapshot.Timestamp.After(q.To) {
+				continue
+			}
+			if pending = append(pending, entry.Snapshot); len(pending) >= archiveBatchSize {
+				if err := flush(); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, nil, err
+	}
+	if stats.Snapshots > 0 {
+		if err := s.RefreshGrowth(ctx, time.Now().UTC()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &manifest, &stats, nil
+}
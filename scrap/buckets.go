@@ -0,0 +1,112 @@
+package scrap
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Resolution is the size of the buckets snapshot series are aggregated into.
+type Resolution string
+
+const (
+	ResolutionHour  Resolution = "hour"
+	ResolutionDay   Resolution = "day"
+	ResolutionWeek  Resolution = "week"
+	ResolutionMonth Resolution = "month"
+)
+
+var ErrUnknownResolution = errors.New("resolution must be one of hour, day, week or month")
+
+func ParseResolution(s string) (Resolution, error) {
+	switch r := Resolution(s); r {
+	case ResolutionHour, ResolutionDay, ResolutionWeek, ResolutionMonth:
+		return r, nil
+	}
+	return "", errors.WithStack(ErrUnknownResolution)
+}
+
+// ParseTime parses an RFC 3339 timestamp or a date like "2006-01-02" as used
+// by the time range parameters of the API and the CLI. An empty string is the
+// zero time.
+func ParseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, errors.Errorf(`expected an RFC 3339 timestamp or a date like "2006-01-02" but got "%s"`, v)
+	}
+	return t, nil
+}
+
+// Truncate returns the start of the bucket t falls into. Weeks start on Monday.
+func (r Resolution) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch r {
+	case ResolutionHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case ResolutionWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case ResolutionMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the bucket following the one starting at t.
+func (r Resolution) Next(t time.Time) time.Time {
+	switch r {
+	case ResolutionHour:
+		return t.Add(time.Hour)
+	case ResolutionWeek:
+		return t.AddDate(0, 0, 7)
+	case ResolutionMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// SnapshotBucket holds the last snapshot values within a bucket and how much
+// they grew since the previous bucket.
+type SnapshotBucket struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Stars      int64     `json:"star_count"`
+	Pulls      int64     `json:"pull_count"`
+	StarsDelta int64     `json:"star_count_delta"`
+	PullsDelta int64     `json:"pull_count_delta"`
+}
+
+type SnapshotBuckets []SnapshotBucket
+
+// Bucket aggregates chronologically ordered snapshots into buckets of the
+// given resolution. Buckets without snapshots are omitted. The delta of the
+// first bucket is relative to its first snapshot.
+func (s RepositorySnapshots) Bucket(r Resolution) SnapshotBuckets {
+	buckets := SnapshotBuckets{}
+	if len(s) == 0 {
+		return buckets
+	}
+
+	previousStars, previousPulls := s[0].Stars, s[0].Pulls
+	for _, snapshot := range s {
+		start := r.Truncate(snapshot.Timestamp)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Timestamp.Equal(start) {
+			if len(buckets) > 0 {
+				last := buckets[len(buckets)-1]
+				previousStars, previousPulls = last.Stars, last.Pulls
+			}
+			buckets = append(buckets, SnapshotBucket{Timestamp: start})
+		}
+
+		b := &buckets[len(buckets)-1]
+		b.Stars, b.Pulls = snapshot.Stars, snapshot.Pulls
+		b.StarsDelta, b.PullsDelta = snapshot.Stars-previousStars, snapshot.Pulls-previousPulls
+	}
+
+	return buckets
+}
@@ -0,0 +1,71 @@
+package scrap
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxComparedRepositories limits how many repositories can be compared at once.
+const MaxComparedRepositories = 10
+
+var ErrTooManyRepositories = errors.Errorf("at most %d repositories can be compared", MaxComparedRepositories)
+
+// Comparison holds the series of several repositories on a common bucket grid
+// so that they can be charted together. Values are null where a repository has
+// no snapshot in a bucket.
+type Comparison struct {
+	Resolution Resolution         `json:"resolution"`
+	Timestamps []time.Time        `json:"timestamps"`
+	Series     []ComparisonSeries `json:"series"`
+}
+
+type ComparisonSeries struct {
+	Registry string   `json:"registry"`
+	Slug     string   `json:"slug"`
+	Stars    []*int64 `json:"star_count"`
+	Pulls    []*int64 `json:"pull_count"`
+}
+
+func (i *Scraper) Compare(ctx context.Context, registry string, slugs []string, from, to time.Time, resolution Resolution) (*Comparison, error) {
+	if len(slugs) > MaxComparedRepositories {
+		return nil, errors.WithStack(ErrTooManyRepositories)
+	}
+
+	series := make([]SnapshotBuckets, len(slugs))
+	for k, slug := range slugs {
+		snapshots, err := i.FindSnapshots(ctx, registry, slug, from, to)
+		if err != nil {
+			return nil, err
+		}
+		series[k] = snapshots.Bucket(resolution)
+	}
+
+	grid, aligned := AlignBuckets(resolution, series...)
+	c := &Comparison{
+		Resolution: resolution,
+		Timestamps: grid,
+		Series:     make([]ComparisonSeries, len(slugs)),
+	}
+	if c.Timestamps == nil {
+		c.Timestamps = []time.Time{}
+	}
+
+	for k, slug := range slugs {
+		s := ComparisonSeries{
+			Registry: registry,
+			Slug:     slug,
+			Stars:    make([]*int64, len(grid)),
+			Pulls:    make([]*int64, len(grid)),
+		}
+		for g, b := range aligned[k] {
+			if b != nil {
+				s.Stars[g], s.Pulls[g] = &b.Stars, &b.Pulls
+			}
+		}
+		c.Series[k] = s
+	}
+
+	return c, nil
+}
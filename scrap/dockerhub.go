@@ -0,0 +1,290 @@
+package scrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/atomic"
+
+	"github.com/ory/x/httpx"
+)
+
+type DockerHub struct {
+	c        *http.Client
+	l        logrus.FieldLogger
+	accounts []*hubAccount
+	next     atomic.Uint64
+	pageSize int
+	delay    time.Duration
+	tagPages int
+}
+
+var _ TagRegistry = new(DockerHub)
+
+func NewDockerHub(
+	l logrus.FieldLogger,
+	pageSize int,
+	delay time.Duration,
+	tagPages int,
+	credentials ...DockerHubCredentials,
+) *DockerHub {
+	accounts := make([]*hubAccount, len(credentials))
+	for k, c := range credentials {
+		accounts[k] = newHubAccount(l, c)
+	}
+	if len(accounts) == 0 {
+		accounts = []*hubAccount{newHubAccount(l, DockerHubCredentials{})}
+	}
+
+	return &DockerHub{
+		l:        l,
+		accounts: accounts,
+		pageSize: pageSize,
+		delay:    delay,
+		tagPages: tagPages,
+		c: &http.Client{
+			Timeout:   time.Second * 30,
+			Transport: httpx.NewDefaultResilientRoundTripper(time.Second*10, time.Second*30),
+		},
+	}
+}
+
+// NormalizeSlug trims the slug and prefixes official images such as "nginx"
+// with "library/".
+func NormalizeSlug(slug string) string {
+	slug = strings.TrimSpace(slug)
+	if slug != "" && !strings.Contains(slug, "/") {
+		slug = "library/" + slug
+	}
+	return slug
+}
+
+func (d *DockerHub) Name() string {
+	return DockerHubRegistry
+}
+
+func (d *DockerHub) FetchSnapshot(ctx context.Context, slug string) (*RepositorySnapshot, error) {
+	uri := "https://hub.docker.com/v2/repositories/" + strings.TrimSpace(
+		strings.Trim(
+			slug, "\n",
+		),
+	) + "/"
+
+	d.l.Debugf(`Fetching repository data for "%s" from: %s`, slug, uri)
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res, err := d.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var dr RepositorySnapshot
+	if err := json.NewDecoder(res.Body).Decode(&dr); err != nil {
+		return nil, errors.WithStack(&decodeError{err: err})
+	}
+
+	return &dr, nil
+}
+
+// FetchTags fetches up to tagPages pages of 100 tags, most recently updated
+// first.
+func (d *DockerHub) FetchTags(ctx context.Context, slug string) (RepositoryTags, error) {
+	var tags RepositoryTags
+	next := "https://hub.docker.com/v2/repositories/" + strings.TrimSpace(slug) + "/tags?page_size=100&ordering=last_updated"
+	for page := 0; page < d.tagPages && next != ""; page++ {
+		d.l.Debugf(`Fetching tags for "%s" from: %s`, slug, next)
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		res, err := d.do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result tagsResult
+		if err := checkStatus(res, http.StatusOK); err != nil {
+			res.Body.Close()
+			return nil, err
+		} else if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			res.Body.Close()
+			return nil, errors.WithStack(&decodeError{err: err})
+		}
+		res.Body.Close()
+
+		for _, r := range result.Results {
+			tags = append(tags, newRepositoryTag(r))
+		}
+		next = result.Next
+	}
+
+	sortTags(tags)
+	return tags, nil
+}
+
+func newRepositoryTag(r tagResult) *RepositoryTag {
+	tag := &RepositoryTag{
+		Name:          r.Name,
+		Digest:        r.Digest,
+		Size:          r.FullSize,
+		LastPushed:    r.TagLastPushed.UTC(),
+		Architectures: Architectures{},
+	}
+	if tag.LastPushed.IsZero() {
+		tag.LastPushed = r.LastUpdated.UTC()
+	}
+
+	seen := make(map[string]bool)
+	var size int64
+	for _, image := range r.Images {
+		if tag.Digest == "" {
+			tag.Digest = image.Digest
+		}
+		size += image.Size
+
+		arch := image.Architecture
+		if image.Variant != "" {
+			arch += "/" + image.Variant
+		}
+		if arch != "" && !seen[arch] {
+			seen[arch] = true
+			tag.Architectures = append(tag.Architectures, arch)
+		}
+	}
+	if tag.Size == 0 {
+		tag.Size = size
+	}
+	sort.Strings(tag.Architectures)
+	return tag
+}
+
+// do executes the request with the next account which has rate limit budget
+// left. Rate limits are shared by all snapshot tasks and discovery. If the
+// account's token was rejected, it logs in again and retries once.
+func (d *DockerHub) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	a, err := d.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := a.authorize(ctx, d.c, req); err != nil {
+			return nil, err
+		}
+
+		res, err := d.c.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		a.limiter.observe(res)
+		registryResponsesTotal.WithLabelValues(DockerHubRegistry, strconv.Itoa(res.StatusCode)).Inc()
+		if res.StatusCode == http.StatusUnauthorized && !a.anonymous() && attempt == 0 {
+			d.l.Debugf("Docker Hub token of account %s was rejected, logging in again", a.credentials.Username)
+			res.Body.Close()
+			a.invalidate()
+			continue
+		}
+
+		return res, nil
+	}
+}
+
+func (d *DockerHub) Discover(ctx context.Context, found func(slugs []string) error) error {
+	uris := []string{
+		fmt.Sprintf("https://hub.docker.com/api/content/v1/products/search?q=&type=image&page_size=%d", d.pageSize),
+		fmt.Sprintf("https://hub.docker.com/api/content/v1/products/search?sort=updated_at&order=desc&type=image&page_size=%d", d.pageSize),
+	}
+
+	for _, uri := range uris {
+		d.l.Debugf("Discovering repositories from: %s", uri)
+		if err := d.discover(ctx, uri, found); isCanceled(err) {
+			return err
+		} else if err != nil {
+			d.l.WithError(err).Errorf("An error occurred during repository discovery.")
+		}
+		d.l.Debugf("Discovery finished for: %s", uri)
+	}
+
+	return nil
+}
+
+func (d *DockerHub) discover(ctx context.Context, next string, found func(slugs []string) error) error {
+	for len(next) > 0 {
+		d.l.Debugf("Discovering next uri: %s", next)
+		result, err := d.fetchDiscovery(ctx, next)
+		if err != nil {
+			return errors.Wrapf(err, `discover: "%s"`, next)
+		}
+
+		slugs := make([]string, len(result.Summaries))
+		for k, r := range result.Summaries {
+			slugs[k] = r.Slug
+		}
+
+		d.l.Debugf("Updating repository index based on uri result: %s", next)
+		if err := found(slugs); err != nil {
+			return errors.Wrapf(err, `discover: "%s"`, next)
+		}
+		d.l.Debugf("Repository index update done!")
+
+		next = result.Next
+		d.l.Debugf("Going to sleep for %.2fs before discovering next uri: %s", d.delay.Seconds(), next)
+		if !sleep(ctx, d.delay) {
+			return errors.WithStack(ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+func (d *DockerHub) fetchDiscovery(ctx context.Context, uri string) (*discoveryResult, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "discovery: %s", uri)
+	}
+	req.Header.Set("Search-Version", "v3")
+
+	res, err := d.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := checkStatus(res, http.StatusOK); err != nil {
+		return nil, errors.Wrapf(err, "discovery: %s", uri)
+	}
+
+	var s discoveryResult
+	if err := json.NewDecoder(res.Body).Decode(&s); err != nil {
+		return nil, errors.Wrapf(err, "discovery: %s", uri)
+	}
+
+	for k, repo := range s.Summaries {
+		if !strings.Contains(repo.Slug, "/") {
+			s.Summaries[k].Slug = strings.TrimSpace(
+				strings.Trim(
+					"library/"+repo.Slug, "\n",
+				),
+			)
+		}
+	}
+
+	return &s, nil
+}
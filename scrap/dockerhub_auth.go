@@ -0,0 +1,154 @@
+package scrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const dockerHubLoginURL = "https://hub.docker.com/v2/users/login"
+
+// DockerHubCredentials is a Docker Hub username and personal access token.
+type DockerHubCredentials struct {
+	Username string
+	Token    string
+}
+
+// ParseDockerHubCredentials parses credentials of the form "username:token".
+func ParseDockerHubCredentials(values []string) ([]DockerHubCredentials, error) {
+	credentials := make([]DockerHubCredentials, 0, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(strings.TrimSpace(v), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf(`docker hub credentials must be formatted as "username:token" but got an entry with %d parts`, len(parts))
+		}
+		credentials = append(credentials, DockerHubCredentials{Username: parts[0], Token: parts[1]})
+	}
+	return credentials, nil
+}
+
+// hubAccount is a Docker Hub identity with its own rate limit budget. The
+// anonymous account has no credentials and never logs in.
+type hubAccount struct {
+	sync.Mutex
+
+	credentials DockerHubCredentials
+	limiter     *rateLimiter
+	token       string
+}
+
+func newHubAccount(l logrus.FieldLogger, credentials DockerHubCredentials) *hubAccount {
+	name := credentials.Username
+	if name == "" {
+		name = "anonymous"
+	}
+	return &hubAccount{
+		credentials: credentials,
+		limiter:     newRateLimiter(l.WithField("account", name)),
+	}
+}
+
+func (a *hubAccount) anonymous() bool {
+	return a.credentials.Username == ""
+}
+
+// authorize adds the account's JWT to the request, logging in first if no
+// token is cached.
+func (a *hubAccount) authorize(ctx context.Context, c *http.Client, req *http.Request) error {
+	if a.anonymous() {
+		return nil
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if a.token == "" {
+		token, err := a.login(ctx, c)
+		if err != nil {
+			return err
+		}
+		a.token = token
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+// invalidate drops the cached token so that the next request logs in again.
+func (a *hubAccount) invalidate() {
+	a.Lock()
+	defer a.Unlock()
+	a.token = ""
+}
+
+func (a *hubAccount) login(ctx context.Context, c *http.Client) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"username": a.credentials.Username,
+		"password": a.credentials.Token,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest("POST", dockerHubLoginURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrapf(err, "login: %s", a.credentials.Username)
+	}
+	defer res.Body.Close()
+
+	a.limiter.observe(res)
+	registryResponsesTotal.WithLabelValues(DockerHubRegistry, strconv.Itoa(res.StatusCode)).Inc()
+	if err := checkStatus(res, http.StatusOK); err != nil {
+		return "", errors.Wrapf(err, "login: %s", a.credentials.Username)
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return "", errors.Wrapf(err, "login: %s", a.credentials.Username)
+	}
+
+	return result.Token, nil
+}
+
+// account returns the next account in round-robin order which is not paused
+// by its rate limit. If all accounts are paused, it waits for the one whose
+// budget resets first.
+func (d *DockerHub) account(ctx context.Context) (*hubAccount, error) {
+	now := time.Now()
+	start := int(d.next.Inc())
+
+	var earliest *hubAccount
+	var earliestUntil time.Time
+	for k := range d.accounts {
+		a := d.accounts[(start+k)%len(d.accounts)]
+		until := a.limiter.pausedUntil()
+		if !until.After(now) {
+			return a, nil
+		}
+		if earliest == nil || until.Before(earliestUntil) {
+			earliest, earliestUntil = a, until
+		}
+	}
+
+	d.l.Debugf("All Docker Hub accounts are rate limited, waiting until %s", earliestUntil.Format(time.RFC3339))
+	if err := earliest.limiter.wait(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return earliest, nil
+}
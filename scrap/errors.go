@@ -0,0 +1,101 @@
+package scrap
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorKind classifies why a repository snapshot could not be fetched.
+type ErrorKind string
+
+const (
+	ErrorKindNone ErrorKind = ""
+
+	// ErrorKindGone is used when the repository no longer exists.
+	ErrorKindGone ErrorKind = "gone"
+	// ErrorKindPrivate is used when the repository requires authorization.
+	ErrorKindPrivate ErrorKind = "private"
+	// ErrorKindUnexpected is used for any other unexpected status code.
+	ErrorKindUnexpected ErrorKind = "unexpected"
+
+	// ErrorKindTransient is used when the registry is rate limiting or failing.
+	ErrorKindTransient ErrorKind = "transient"
+	// ErrorKindNetwork is used when the registry could not be reached.
+	ErrorKindNetwork ErrorKind = "network"
+	// ErrorKindDecode is used when the registry's response could not be decoded
+	// or contained an implausible pull count.
+	ErrorKindDecode ErrorKind = "decode"
+)
+
+const (
+	retryBackoffBase = time.Minute * 15
+	retryBackoffMax  = time.Hour * 24 * 7
+)
+
+// Permanent reports whether repositories with this error are excluded from
+// scraping for good instead of being retried.
+func (k ErrorKind) Permanent() bool {
+	switch k {
+	case ErrorKindGone, ErrorKindPrivate, ErrorKindUnexpected:
+		return true
+	}
+	return false
+}
+
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return "unable to decode response: " + e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+// classifyError returns the kind of err and, if err was caused by an
+// unexpected status code, the status code.
+func classifyError(err error) (ErrorKind, int) {
+	if code := statusCode(err); code > 0 {
+		switch {
+		case code == http.StatusNotFound || code == http.StatusGone:
+			return ErrorKindGone, code
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return ErrorKindPrivate, code
+		case isTransientStatus(code):
+			return ErrorKindTransient, code
+		}
+		return ErrorKindUnexpected, code
+	}
+
+	var de *decodeError
+	if errors.As(err, &de) || errors.Is(err, ErrImplausiblePulls) {
+		return ErrorKindDecode, 0
+	}
+
+	return ErrorKindNetwork, 0
+}
+
+// retryBackoff returns how long to wait before retrying a repository which
+// failed retries times in a row.
+func retryBackoff(retries int) time.Duration {
+	backoff := retryBackoffBase
+	for k := 1; k < retries && backoff < retryBackoffMax; k++ {
+		backoff *= 2
+	}
+	if backoff > retryBackoffMax {
+		return retryBackoffMax
+	}
+	return backoff
+}
+
+// isCanceled returns true if err was caused by shutting down. Deadlines are
+// not checked as they are exceeded by requests which timed out, which are
+// network errors.
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,43 @@
+package scrap
+
+import (
+	"context"
+	"time"
+)
+
+// Export sets the dockerstats_pulls_total and dockerstats_stars gauges from the
+// latest snapshot of every watched repository every interval until ctx is
+// canceled. Watched repositories which have not been discovered yet are added
+// as manual repositories so that they are scrapped.
+func (i *Scraper) Export(ctx context.Context, registry string, slugs []string, every time.Duration) error {
+	if err := i.store.AddRepositories(ctx, registry, SourceManual, slugs); isCanceled(err) {
+		return nil
+	} else if err != nil {
+		i.l.WithError(err).Errorf("Unable to add exported repositories")
+	}
+
+	for {
+		for _, slug := range slugs {
+			if err := i.export(ctx, registry, slug); isCanceled(err) {
+				return nil
+			} else if err != nil {
+				i.l.WithError(err).Errorf("Unable to export repository: %s", slug)
+			}
+		}
+
+		if !sleep(ctx, every) {
+			return nil
+		}
+	}
+}
+
+func (i *Scraper) export(ctx context.Context, registry, slug string) error {
+	latest, err := i.FindLatestSnapshot(ctx, registry, slug)
+	if err != nil || latest == nil {
+		return err
+	}
+
+	repositoryPulls.WithLabelValues(slug).Set(float64(latest.Pulls))
+	repositoryStars.WithLabelValues(slug).Set(float64(latest.Stars))
+	return nil
+}
@@ -0,0 +1,53 @@
+package scrap
+
+import "time"
+
+// Growth is the increase of pulls and stars between two snapshots. Because
+// snapshots are not evenly spaced, the increase is also given per day.
+type Growth struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Pulls       int64     `json:"pull_count_increment"`
+	Stars       int64     `json:"star_count_increment"`
+	PullsPerDay float64   `json:"pulls_per_day"`
+	StarsPerDay float64   `json:"stars_per_day"`
+}
+
+type GrowthSeries []Growth
+
+// Growth returns the increments between consecutive, chronologically ordered
+// snapshots.
+func (s RepositorySnapshots) Growth() GrowthSeries {
+	series := GrowthSeries{}
+	for k := 1; k < len(s); k++ {
+		previous, current := s[k-1], s[k]
+		days := current.Timestamp.Sub(previous.Timestamp).Hours() / 24
+		if days <= 0 {
+			continue
+		}
+
+		g := Growth{
+			From:  previous.Timestamp,
+			To:    current.Timestamp,
+			Pulls: current.Pulls - previous.Pulls,
+			Stars: current.Stars - previous.Stars,
+		}
+		g.PullsPerDay = float64(g.Pulls) / days
+		g.StarsPerDay = float64(g.Stars) / days
+		series = append(series, g)
+	}
+	return series
+}
+
+// Last returns the last snapshot of every bucket of the given resolution.
+func (s RepositorySnapshots) Last(r Resolution) RepositorySnapshots {
+	last := RepositorySnapshots{}
+	for _, snapshot := range s {
+		if n := len(last); n > 0 && r.Truncate(last[n-1].Timestamp).Equal(r.Truncate(snapshot.Timestamp)) {
+			last[n-1] = snapshot
+			continue
+		}
+		last = append(last, snapshot)
+	}
+	return last
+}
@@ -0,0 +1,86 @@
+package scrap
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LeaderboardWindows are the windows, in days, pull growth is rolled up for.
+var LeaderboardWindows = []int{1, 7, 30}
+
+var ErrUnknownLeaderboardWindow = errors.New("window must be one of 1, 7 or 30 days")
+
+// LeaderboardOrder selects whether repositories are ranked by absolute or
+// relative pull growth.
+type LeaderboardOrder string
+
+const (
+	LeaderboardOrderAbsolute LeaderboardOrder = "absolute"
+	LeaderboardOrderRelative LeaderboardOrder = "relative"
+)
+
+var ErrUnknownLeaderboardOrder = errors.New("order must be one of absolute or relative")
+
+func ParseLeaderboardOrder(s string) (LeaderboardOrder, error) {
+	switch o := LeaderboardOrder(s); o {
+	case LeaderboardOrderAbsolute, LeaderboardOrderRelative:
+		return o, nil
+	}
+	return "", errors.WithStack(ErrUnknownLeaderboardOrder)
+}
+
+func (o LeaderboardOrder) column() string {
+	if o == LeaderboardOrderRelative {
+		return "pulls_growth_relative"
+	}
+	return "pulls_growth"
+}
+
+type LeaderboardQuery struct {
+	Registry string
+	Window   int
+	Order    LeaderboardOrder
+
+	// MinPulls excludes repositories with fewer pulls, which would otherwise
+	// dominate the relative ranking.
+	MinPulls int64
+	Limit    int
+}
+
+func (q LeaderboardQuery) Validate() error {
+	for _, w := range LeaderboardWindows {
+		if w == q.Window {
+			return nil
+		}
+	}
+	return errors.WithStack(ErrUnknownLeaderboardWindow)
+}
+
+// RepositoryGrowth is the pull and star growth of a repository within the
+// window, computed from its latest snapshot and the last snapshot before the
+// window started.
+type RepositoryGrowth struct {
+	Registry            string    `json:"registry" db:"registry"`
+	Slug                string    `json:"slug" db:"slug"`
+	Window              int       `json:"window_days" db:"window_days"`
+	Pulls               int64     `json:"pull_count" db:"pulls"`
+	PullsGrowth         int64     `json:"pull_count_growth" db:"pulls_growth"`
+	PullsGrowthRelative float64   `json:"pull_count_growth_relative" db:"pulls_growth_relative"`
+	StarsGrowth         int64     `json:"star_count_growth" db:"stars_growth"`
+	ComputedAt          time.Time `json:"computed_at" db:"computed_at"`
+}
+
+func newRepositoryGrowth(window int, baseline, latest *RepositorySnapshot, computedAt time.Time) RepositoryGrowth {
+	g := RepositoryGrowth{
+		Window:      window,
+		Pulls:       latest.Pulls,
+		PullsGrowth: latest.Pulls - baseline.Pulls,
+		StarsGrowth: latest.Stars - baseline.Stars,
+		ComputedAt:  computedAt,
+	}
+	if baseline.Pulls > 0 {
+		g.PullsGrowthRelative = float64(g.PullsGrowth) / float64(baseline.Pulls)
+	}
+	return g
+}
@@ -0,0 +1,56 @@
+package scrap
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	snapshotFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "dockerstats_snapshot_fetch_duration_seconds",
+		Help: "Time it took to fetch a snapshot from the registry.",
+	}, []string{"registry"})
+	snapshotsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerstats_snapshots_total",
+		Help: "Snapshots processed by result, which is either success or the error kind.",
+	}, []string{"registry", "result"})
+	registryResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerstats_registry_responses_total",
+		Help: "HTTP responses received from registries by status code.",
+	}, []string{"registry", "code"})
+	tagFetchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerstats_tag_fetches_total",
+		Help: "Tag lists fetched together with snapshots by result, which is either success or error.",
+	}, []string{"registry", "result"})
+	discoveryPagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerstats_discovery_pages_total",
+		Help: "Discovery result pages persisted.",
+	}, []string{"registry"})
+	snapshotQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dockerstats_snapshot_queue_depth",
+		Help: "Repositories waiting in the snapshot queue of this process.",
+	})
+	snapshotsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dockerstats_snapshots_in_flight",
+		Help: "Repositories queued or being fetched by this process.",
+	})
+	repositoryPulls = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dockerstats_pulls_total",
+		Help: "Pull count of the latest snapshot of a watched repository.",
+	}, []string{"repo"})
+	repositoryStars = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dockerstats_stars",
+		Help: "Star count of the latest snapshot of a watched repository.",
+	}, []string{"repo"})
+	storeQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "dockerstats_store_query_duration_seconds",
+		Help: "Time it took to execute a store operation.",
+	}, []string{"operation"})
+)
+
+// since observes the time passed since start in seconds.
+func since(h *prometheus.HistogramVec, start time.Time, labels ...string) {
+	h.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
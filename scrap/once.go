@@ -0,0 +1,145 @@
+package scrap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Summary counts the snapshots fetched by ScrapOnce.
+type Summary struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Failures maps the slugs of failed repositories to their error.
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+func (s *Summary) add(repo Repository, err error) {
+	if err == nil {
+		s.Succeeded++
+		return
+	}
+	s.Failed++
+	s.Failures[repo.Slug] = err.Error()
+}
+
+// ScrapOnce fetches a snapshot of the given repositories of registry or, if
+// slugs is empty, of all repositories which are due and returns once all of
+// them were processed. Unknown repositories are added as manual repositories.
+// Given repositories are fetched even if they are not due, but like due ones
+// they are leased first and skipped if another instance holds a lease. Like
+// Scrap, it completes in-flight snapshots when ctx is canceled and skips the
+// remaining ones.
+func (i *Scraper) ScrapOnce(ctx context.Context, registry string, slugs []string) (*Summary, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+
+	work, cancel := withGracePeriod(ctx, drainGracePeriod)
+	defer cancel()
+
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	var errs errorCollector
+	summary := &Summary{Failures: map[string]string{}}
+	report := func(repo Repository, err error) {
+		m.Lock()
+		summary.add(repo, err)
+		m.Unlock()
+	}
+	queue := make(chan Repository, i.taskCount)
+	for t := 0; t < i.taskCount; t++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				if ctx.Err() != nil {
+					errs.add(i.store.ReleaseRepository(work, repo.Registry, repo.Slug, i.instance))
+					i.snapshotQueuePop(repo)
+					continue
+				}
+
+				err := i.fetchSnapshot(work, repo)
+				if err != nil {
+					i.l.WithError(err).Errorf("Unable to scrap repository")
+				}
+				report(repo, err)
+			}
+		}()
+	}
+
+	if len(slugs) > 0 {
+		errs.add(i.enqueueRepositories(ctx, registry, slugs, queue, report))
+	} else {
+		errs.add(i.enqueueDueRepositories(ctx, queue))
+	}
+	wg.Wait()
+
+	if summary.Succeeded > 0 && ctx.Err() == nil {
+		errs.add(i.store.RefreshGrowth(ctx, time.Now().UTC()))
+	}
+	return summary, errs.err()
+}
+
+// enqueueRepositories leases the repositories one by one and enqueues them.
+// Repositories leased by another instance are passed to skip.
+func (i *Scraper) enqueueRepositories(ctx context.Context, registry string, slugs []string, queue chan Repository, skip func(Repository, error)) error {
+	defer close(queue)
+
+	if err := i.store.AddRepositories(ctx, registry, SourceManual, slugs); err != nil {
+		return err
+	}
+	for _, slug := range slugs {
+		now := time.Now().UTC()
+		repo, err := i.store.LeaseRepository(ctx, registry, slug, i.instance, now, now.Add(i.leaseDuration))
+		if isCanceled(err) {
+			return nil
+		} else if errors.Is(err, ErrRepositoryLeased) {
+			skip(Repository{Registry: registry, Slug: slug}, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !i.snapshotQueuePush(ctx, *repo, queue) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// enqueueDueRepositories leases due repositories until none are left. Fetched
+// and failed repositories are no longer due and the others stay leased. Leases
+// of repositories which wait in the queue for longer than the lease duration
+// expire though, so the loop also ends once a round only leased repositories
+// which are still in flight.
+func (i *Scraper) enqueueDueRepositories(ctx context.Context, queue chan Repository) error {
+	defer close(queue)
+
+	for {
+		now := time.Now().UTC()
+		is, err := i.store.LeaseRepositories(ctx, i.instance, now, i.due(now), now.Add(i.leaseDuration), 500)
+		if isCanceled(err) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if len(is) == 0 {
+			return nil
+		}
+
+		var enqueued int
+		for _, repo := range is {
+			if !i.snapshotInFlight(repo) {
+				enqueued++
+			}
+			if !i.snapshotQueuePush(ctx, repo, queue) {
+				return nil
+			}
+		}
+		if enqueued == 0 {
+			return nil
+		}
+	}
+}
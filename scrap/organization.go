@@ -0,0 +1,161 @@
+package scrap
+
+import (
+	"context"
+	"time"
+)
+
+// Organization aggregates all repositories of a namespace such as "library".
+type Organization struct {
+	Registry     string                   `json:"registry"`
+	Name         string                   `json:"name"`
+	History      SnapshotBuckets          `json:"history"`
+	Repositories []OrganizationRepository `json:"repositories"`
+	Growth       OrganizationGrowth       `json:"growth"`
+}
+
+// OrganizationRepository holds the latest numbers of a repository within the
+// requested time range and its share of the organization's pull growth.
+type OrganizationRepository struct {
+	Slug             string    `json:"slug"`
+	Timestamp        time.Time `json:"timestamp"`
+	Stars            int64     `json:"star_count"`
+	Pulls            int64     `json:"pull_count"`
+	PullsGrowth      int64     `json:"pull_count_growth"`
+	PullsGrowthShare float64   `json:"pull_count_growth_share"`
+}
+
+// OrganizationGrowth is the organization's pull growth within the window and
+// its share of the pull growth of the whole registry.
+type OrganizationGrowth struct {
+	Window           int     `json:"window_days"`
+	PullsGrowth      int64   `json:"pull_count_growth"`
+	PullsGrowthShare float64 `json:"pull_count_growth_share"`
+}
+
+// FindOrganization sums the snapshot history of all repositories in the
+// organization. Growth is taken from the rollup of the last snapshot cycle.
+func (i *Scraper) FindOrganization(ctx context.Context, registry, org string, from, to time.Time, resolution Resolution, window int) (*Organization, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+	if err := (LeaderboardQuery{Window: window}).Validate(); err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = maxDate
+	}
+
+	prefix := org + "/"
+	var repositories []Repository
+	for after := ""; ; {
+		page, err := i.store.ListRepositories(ctx, RepositoryQuery{Registry: registry, Prefix: prefix, After: after, Limit: 1000})
+		if err != nil {
+			return nil, err
+		} else if len(page) == 0 {
+			break
+		}
+		repositories = append(repositories, page...)
+		after = page[len(page)-1].Slug
+	}
+
+	growth, err := i.store.ListGrowth(ctx, registry, window, prefix)
+	if err != nil {
+		return nil, err
+	}
+	total, err := i.store.SumGrowth(ctx, registry, window, "")
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Organization{
+		Registry:     registry,
+		Name:         org,
+		Repositories: make([]OrganizationRepository, len(repositories)),
+		Growth:       OrganizationGrowth{Window: window},
+	}
+
+	grown := make(map[string]int64, len(growth))
+	for _, g := range growth {
+		grown[g.Slug] = g.PullsGrowth
+		o.Growth.PullsGrowth += g.PullsGrowth
+	}
+	if total != 0 {
+		o.Growth.PullsGrowthShare = float64(o.Growth.PullsGrowth) / float64(total)
+	}
+
+	index := make(map[string]int, len(repositories))
+	for k, r := range repositories {
+		index[r.Slug] = k
+		o.Repositories[k] = OrganizationRepository{Slug: r.Slug, PullsGrowth: grown[r.Slug]}
+		if o.Growth.PullsGrowth != 0 {
+			o.Repositories[k].PullsGrowthShare = float64(grown[r.Slug]) / float64(o.Growth.PullsGrowth)
+		}
+	}
+
+	// The history of all repositories is read in a single query which returns
+	// the snapshots grouped by slug.
+	series := make([]SnapshotBuckets, len(repositories))
+	var slug string
+	var snapshots RepositorySnapshots
+	flush := func() {
+		k, ok := index[slug]
+		if !ok || len(snapshots) == 0 {
+			return
+		}
+		series[k] = snapshots.Bucket(resolution)
+		last := snapshots[len(snapshots)-1]
+		or := &o.Repositories[k]
+		or.Timestamp, or.Stars, or.Pulls = last.Timestamp, last.Stars, last.Pulls
+	}
+	if err := i.store.WalkSnapshots(ctx, SnapshotQuery{Registry: registry, Prefix: prefix, From: from, To: to}, func(r *SnapshotRecord) error {
+		if r.Slug != slug {
+			flush()
+			slug, snapshots = r.Slug, nil
+		}
+		snapshot := r.RepositorySnapshot
+		snapshots = append(snapshots, &snapshot)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	flush()
+
+	o.History = sumBuckets(resolution, series...)
+	return o, nil
+}
+
+// sumBuckets adds up the series bucket by bucket. A series which has no bucket
+// at a point of the grid contributes its previous bucket so that gaps in one
+// repository's history do not show up as drops in the sum.
+func sumBuckets(r Resolution, series ...SnapshotBuckets) SnapshotBuckets {
+	grid, aligned := AlignBuckets(r, series...)
+
+	sum := make(SnapshotBuckets, len(grid))
+	for g, t := range grid {
+		sum[g].Timestamp = t
+	}
+
+	for _, s := range aligned {
+		var previous *SnapshotBucket
+		for g, b := range s {
+			delta := b != nil
+			if b == nil {
+				b = previous
+			}
+			if b == nil {
+				continue
+			}
+
+			sum[g].Stars += b.Stars
+			sum[g].Pulls += b.Pulls
+			if delta {
+				sum[g].StarsDelta += b.StarsDelta
+				sum[g].PullsDelta += b.PullsDelta
+			}
+			previous = b
+		}
+	}
+
+	return sum
+}
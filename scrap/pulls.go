@@ -0,0 +1,107 @@
+package scrap
+
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// int32Range is the number of values an int32 can hold.
+	int32Range = int64(1) << 32
+	// maxPullsGrowth is more than any repository is pulled between two
+	// snapshots.
+	maxPullsGrowth = int32Range / 4
+)
+
+// ErrImplausiblePulls is returned for pull counts which grew by more than
+// maxPullsGrowth since the previous snapshot.
+var ErrImplausiblePulls = errors.New("pull count grew implausibly since the previous snapshot")
+
+// correctPulls reconstructs the true pull count from the raw value reported by
+// Docker Hub, which stores pulls as an int32 and thus wraps around (and turns
+// negative) past math.MaxInt32. The true count is the raw count plus the
+// number of wraparounds which brings it closest to the previous corrected
+// count, as pulls never grow by billions between two snapshots. Without a
+// previous snapshot, a negative raw count is assumed to have wrapped once,
+// which is the smallest count it can stand for. A negative previous count was
+// stored before counts were corrected and is corrected the same way first.
+func correctPulls(raw int64, previous *RepositorySnapshot) (int64, error) {
+	if raw < math.MinInt32 || raw > math.MaxInt32 {
+		// Not affected by the int32 overflow.
+		return raw, nil
+	}
+
+	corrected := raw
+	if corrected < 0 {
+		corrected += int32Range
+	}
+
+	if previous == nil {
+		return corrected, nil
+	}
+
+	pulls := previous.Pulls
+	if pulls < 0 {
+		pulls += int32Range
+	}
+	if pulls > corrected {
+		wraps := (pulls - corrected + int32Range/2) / int32Range
+		corrected += wraps * int32Range
+	}
+	if corrected-pulls > maxPullsGrowth {
+		return 0, errors.Wrapf(ErrImplausiblePulls, "raw pull count %d follows %d", raw, previous.Pulls)
+	}
+	return corrected, nil
+}
+
+// correctHistory recomputes the pull counts of chronologically ordered
+// snapshots from their raw counts and returns the snapshots whose count
+// changed. Implausible counts restart the correction as if they were the first
+// snapshot because history can not be fetched again.
+func correctHistory(snapshots RepositorySnapshots) RepositorySnapshots {
+	var changed RepositorySnapshots
+	var previous *RepositorySnapshot
+	for _, snapshot := range snapshots {
+		pulls, err := correctPulls(snapshot.PullsRaw, previous)
+		if err != nil {
+			pulls, _ = correctPulls(snapshot.PullsRaw, nil)
+		}
+		if pulls != snapshot.Pulls {
+			snapshot.Pulls = pulls
+			changed = append(changed, snapshot)
+		}
+		previous = snapshot
+	}
+	return changed
+}
+
+// CorrectAllPulls recomputes the corrected pull counts of all snapshots of the
+// registry's repositories, for example for snapshots stored before pull counts
+// were corrected. It returns the number of updated snapshots.
+func CorrectAllPulls(ctx context.Context, s Store, registry string) (int, error) {
+	var corrected int
+	for after := ""; ; {
+		repositories, err := s.ListRepositories(ctx, RepositoryQuery{
+			Registry: registry,
+			Status:   RepositoryStatusAll,
+			After:    after,
+			Limit:    1000,
+		})
+		if err != nil {
+			return corrected, err
+		} else if len(repositories) == 0 {
+			return corrected, nil
+		}
+
+		for _, r := range repositories {
+			n, err := s.CorrectPulls(ctx, r.Registry, r.Slug)
+			if err != nil {
+				return corrected, err
+			}
+			corrected += n
+		}
+		after = repositories[len(repositories)-1].Slug
+	}
+}
@@ -0,0 +1,137 @@
+package scrap
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCorrectPulls(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		raw      int64
+		previous int64
+		first    bool
+		expected int64
+		err      error
+	}{
+		{name: "first snapshot", raw: 1000, first: true, expected: 1000},
+		{name: "first snapshot with negative raw count assumes one wraparound", raw: -5, first: true, expected: int32Range - 5},
+		{name: "growth without wraparound", raw: 1500, previous: 1000, expected: 1500},
+		{name: "single wraparound", raw: math.MinInt32 + 10, previous: math.MaxInt32 - 10, expected: math.MaxInt32 + 11},
+		{name: "multiple wraparounds", raw: 100, previous: 3*int32Range - 50, expected: 3*int32Range + 100},
+		{name: "negative raw count after multiple wraparounds", raw: -100, previous: 3*int32Range - 200, expected: 3*int32Range - 100},
+		{name: "real decrease", raw: 900, previous: 1000, expected: 900},
+		{name: "real decrease across a wraparound", raw: math.MaxInt32 - 10, previous: math.MaxInt32 + 20, expected: math.MaxInt32 - 10},
+		{name: "unaffected raw count", raw: 3 * int32Range, previous: 3*int32Range - 5, expected: 3 * int32Range},
+		{name: "uncorrected negative previous count", raw: -40, previous: -50, expected: int32Range - 40},
+		{name: "wraparound after an uncorrected negative previous count", raw: 100, previous: -50, expected: int32Range + 100},
+		{name: "negative raw count after a small count", raw: -5, previous: 100, err: ErrImplausiblePulls},
+		{name: "implausible growth", raw: math.MaxInt32, previous: 10, err: ErrImplausiblePulls},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var previous *RepositorySnapshot
+			if !tc.first {
+				previous = &RepositorySnapshot{Pulls: tc.previous}
+			}
+
+			actual, err := correctPulls(tc.raw, previous)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v but got %d, %v", tc.err, actual, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %d but got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStoreCorrectPulls(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		if _, err := s.CorrectPulls(ctx, DockerHubRegistry, "a/a"); !errors.Is(err, ErrRepositoryNotFound) {
+			t.Fatalf("expected ErrRepositoryNotFound but got %v", err)
+		}
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Snapshots stored before pulls were corrected hold the raw count.
+		start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+		raw := []int64{math.MaxInt32 - 10, math.MinInt32 + 10, -5, 20, 30}
+		var snapshots RepositorySnapshots
+		for k, pulls := range raw {
+			snapshots = append(snapshots, &RepositorySnapshot{Pulls: pulls, PullsRaw: pulls, Timestamp: start.AddDate(0, 0, k)})
+		}
+		if _, err := s.ImportSnapshots(ctx, DockerHubRegistry, "a/a", snapshots); err != nil {
+			t.Fatal(err)
+		}
+
+		for k, expected := range []int{4, 0} {
+			n, err := s.CorrectPulls(ctx, DockerHubRegistry, "a/a")
+			if err != nil {
+				t.Fatal(err)
+			} else if n != expected {
+				t.Fatalf("run %d: expected %d corrected snapshots but got %d", k, expected, n)
+			}
+		}
+
+		stored, err := s.ListSnapshots(ctx, DockerHubRegistry, "a/a", zeroDate, maxDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []int64{math.MaxInt32 - 10, math.MaxInt32 + 11, int32Range - 5, int32Range + 20, int32Range + 30}
+		for k, snapshot := range stored {
+			if snapshot.Pulls != expected[k] || snapshot.PullsRaw != raw[k] {
+				t.Fatalf("snapshot %d: expected %d pulls from raw %d but got %+v", k, expected[k], raw[k], snapshot)
+			}
+		}
+
+		// New snapshots continue from the corrected history.
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: 40}, start); err != nil {
+			t.Fatal(err)
+		}
+		if latest, err := s.LatestSnapshot(ctx, DockerHubRegistry, "a/a"); err != nil {
+			t.Fatal(err)
+		} else if latest.Pulls != int32Range+40 {
+			t.Fatalf("expected %d pulls but got %d", int32Range+40, latest.Pulls)
+		}
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: math.MinInt32}, start); !errors.Is(err, ErrImplausiblePulls) {
+			t.Fatalf("expected ErrImplausiblePulls but got %v", err)
+		}
+	})
+}
+
+func TestStoreAddSnapshotAfterUncorrectedHistory(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Migrated snapshots hold the wrapped raw count until "migrate pulls" ran.
+		start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := s.ImportSnapshots(ctx, DockerHubRegistry, "a/a", RepositorySnapshots{
+			{Pulls: -50, PullsRaw: -50, Timestamp: start},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: -40}, start.AddDate(0, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+		if latest, err := s.LatestSnapshot(ctx, DockerHubRegistry, "a/a"); err != nil {
+			t.Fatal(err)
+		} else if latest.Pulls != int32Range-40 {
+			t.Fatalf("expected %d pulls but got %d", int32Range-40, latest.Pulls)
+		}
+	})
+}
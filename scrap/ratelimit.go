@@ -0,0 +1,162 @@
+package scrap
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rateLimitPause is used when the registry responds with 429 but does not tell
+// us how long to back off for.
+const rateLimitPause = time.Minute * 5
+
+// rateLimiter tracks the request budget announced by a registry and pauses
+// everyone sharing it once the budget is exhausted.
+type rateLimiter struct {
+	sync.Mutex
+
+	l         logrus.FieldLogger
+	limit     int
+	remaining int
+	until     time.Time
+}
+
+func newRateLimiter(l logrus.FieldLogger) *rateLimiter {
+	return &rateLimiter{l: l, limit: -1, remaining: -1}
+}
+
+// wait blocks until the budget allows new requests or ctx is done.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		r.Lock()
+		d := time.Until(r.until)
+		r.Unlock()
+
+		if d <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// observe updates the budget from the response's RateLimit-Limit,
+// RateLimit-Remaining, Retry-After and reset headers. Once the budget is
+// exhausted, requests are paused until it resets and only for the whole
+// window if the registry does not tell when that is.
+func (r *rateLimiter) observe(res *http.Response) {
+	now := time.Now()
+	limit, _, hasLimit := parseRateLimit(res.Header.Get("RateLimit-Limit"))
+	remaining, window, hasRemaining := parseRateLimit(res.Header.Get("RateLimit-Remaining"))
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"), now)
+	reset := parseRateLimitReset(res.Header, now)
+	exhausted := res.StatusCode == http.StatusTooManyRequests || (hasRemaining && remaining <= 0)
+
+	var pause time.Duration
+	switch {
+	case retryAfter > 0:
+		pause = retryAfter
+	case exhausted && reset > 0:
+		pause = reset
+	case exhausted && hasRemaining && remaining <= 0 && window > 0:
+		pause = window
+	case res.StatusCode == http.StatusTooManyRequests:
+		pause = rateLimitPause
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if hasLimit {
+		r.limit = limit
+	}
+	if hasRemaining {
+		r.remaining = remaining
+	}
+
+	if pause > 0 && now.Add(pause).After(r.until) {
+		r.until = now.Add(pause)
+		r.l.
+			WithField("limit", r.limit).
+			WithField("remaining", r.remaining).
+			Warnf("Rate limit exhausted, pausing all requests until %s", r.until.Format(time.RFC3339))
+	}
+}
+
+// pausedUntil returns the time requests are paused until.
+func (r *rateLimiter) pausedUntil() time.Time {
+	r.Lock()
+	defer r.Unlock()
+	return r.until
+}
+
+// parseRateLimit parses headers of the form "100;w=21600" into the quota and
+// the length of the quota window.
+func parseRateLimit(header string) (value int, window time.Duration, ok bool) {
+	if header == "" {
+		return 0, 0, false
+	}
+
+	parts := strings.Split(header, ";")
+	value, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 || kv[0] != "w" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(kv[1]); err == nil {
+			window = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return value, window, true
+}
+
+// parseRateLimitReset returns how long it takes until the quota resets from
+// the RateLimit-Reset header, which holds seconds, or the X-RateLimit-Reset
+// header, which holds a Unix timestamp.
+func parseRateLimitReset(header http.Header, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get("RateLimit-Reset"))); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if unix, err := strconv.ParseInt(strings.TrimSpace(header.Get("X-RateLimit-Reset")), 10, 64); err == nil {
+		return time.Unix(unix, 0).Sub(now)
+	}
+	return 0
+}
+
+// parseRetryAfter supports both the delay-seconds and the HTTP-date form.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// isTransientStatus reports whether a status code indicates a condition which
+// is expected to clear on its own and thus must not exclude a repository.
+func isTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,78 @@
+package scrap
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		value  int
+		window time.Duration
+		ok     bool
+	}{
+		{header: "100;w=21600", value: 100, window: time.Hour * 6, ok: true},
+		{header: "0;w=21600", value: 0, window: time.Hour * 6, ok: true},
+		{header: " 100 ; w=60", value: 100, window: time.Minute, ok: true},
+		{header: "100", value: 100, ok: true},
+		{header: "100;w=abc", value: 100, ok: true},
+		{header: ""},
+		{header: "abc;w=60"},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			value, window, ok := parseRateLimit(tc.header)
+			if value != tc.value || window != tc.window || ok != tc.ok {
+				t.Fatalf("expected %d, %s, %t but got %d, %s, %t", tc.value, tc.window, tc.ok, value, window, ok)
+			}
+		})
+	}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		status  int
+		headers map[string]string
+		pause   time.Duration
+	}{
+		{name: "budget left", status: http.StatusOK, headers: map[string]string{"RateLimit-Limit": "100;w=21600", "RateLimit-Remaining": "100;w=21600"}},
+		{name: "budget exhausted without reset pauses for the window", status: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0;w=21600"}, pause: time.Hour * 6},
+		{name: "budget exhausted pauses until the reset", status: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0;w=21600", "RateLimit-Reset": "60"}, pause: time.Minute},
+		{name: "budget exhausted pauses until the unix reset", status: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0;w=21600", "X-RateLimit-Reset": strconv.FormatInt(time.Now().Add(time.Minute*2).Unix(), 10)}, pause: time.Minute * 2},
+		{name: "budget exhausted without window", status: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0"}},
+		{name: "reset without exhausted budget", status: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "10;w=21600", "RateLimit-Reset": "60"}},
+		{name: "too many requests without headers", status: http.StatusTooManyRequests, pause: rateLimitPause},
+		{name: "too many requests with window", status: http.StatusTooManyRequests, headers: map[string]string{"RateLimit-Remaining": "0;w=3600"}, pause: time.Hour},
+		{name: "too many requests with reset", status: http.StatusTooManyRequests, headers: map[string]string{"RateLimit-Reset": "30"}, pause: time.Second * 30},
+		{name: "retry after seconds", status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "120", "RateLimit-Remaining": "0;w=21600", "RateLimit-Reset": "60"}, pause: time.Minute * 2},
+		{name: "retry after date", status: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": time.Now().Add(time.Minute * 10).UTC().Format(http.TimeFormat)}, pause: time.Minute * 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+			for k, v := range tc.headers {
+				res.Header.Set(k, v)
+			}
+
+			r := newRateLimiter(logrus.New())
+			start := time.Now()
+			r.observe(res)
+
+			until := r.pausedUntil()
+			if tc.pause == 0 {
+				if !until.IsZero() {
+					t.Fatalf("expected no pause but got one until %s", until)
+				}
+				return
+			}
+			// HTTP dates and Unix timestamps only have second precision.
+			if d := until.Sub(start) - tc.pause; d < -time.Second*2 || d > time.Second {
+				t.Fatalf("expected a pause of %s but got %s", tc.pause, until.Sub(start))
+			}
+		})
+	}
+}
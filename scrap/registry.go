@@ -0,0 +1,42 @@
+package scrap
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// DockerHubRegistry is the registry name Docker Hub repositories are stored with.
+const DockerHubRegistry = "docker"
+
+var ErrUnknownRegistry = errors.New("registry is not configured")
+
+// Registry is a container registry which can be crawled for repositories and
+// asked for the current statistics of a single repository.
+type Registry interface {
+	// Name is used to namespace the registry's repositories in the database.
+	Name() string
+
+	// Discover crawls the registry and calls found for every page of slugs.
+	Discover(ctx context.Context, found func(slugs []string) error) error
+
+	// FetchSnapshot fetches the current pull and star count of a repository.
+	FetchSnapshot(ctx context.Context, slug string) (*RepositorySnapshot, error)
+}
+
+// TagRegistry is a registry which can also list the tags of a repository.
+type TagRegistry interface {
+	Registry
+
+	// FetchTags fetches the most recently pushed tags of a repository. It
+	// returns no tags if fetching tags is disabled.
+	FetchTags(ctx context.Context, slug string) (RepositoryTags, error)
+}
+
+func (i *Scraper) registry(name string) (Registry, error) {
+	r, ok := i.registries[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownRegistry, "registry: %s", name)
+	}
+	return r, nil
+}
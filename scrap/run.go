@@ -0,0 +1,105 @@
+package scrap
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// drainGracePeriod is how long in-flight snapshots may take to complete once
+// the scraper is asked to stop.
+const drainGracePeriod = time.Second * 30
+
+// Errors aggregates the errors which occurred while the scraper was running.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for k, err := range e {
+		messages[k] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+type errorCollector struct {
+	sync.Mutex
+	errs Errors
+}
+
+func (c *errorCollector) add(err error) {
+	if err == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func (c *errorCollector) err() error {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+// Run discovers repositories and fetches snapshots until ctx is canceled.
+// Snapshots which are in flight when ctx is canceled are completed before Run
+// returns. Discovery is disabled if the discovery interval is not positive, in
+// which case only known repositories are scrapped.
+func (i *Scraper) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	var errs errorCollector
+
+	if i.discoverEvery > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs.add(i.Discover(ctx))
+		}()
+	} else {
+		i.l.Infoln("Repository discovery is disabled")
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs.add(i.Scrap(ctx))
+	}()
+
+	wg.Wait()
+	return errs.err()
+}
+
+// withGracePeriod returns a context which is canceled once grace has passed
+// after parent is done, giving in-flight work the chance to complete.
+func withGracePeriod(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-parent.Done():
+			select {
+			case <-time.After(grace):
+			case <-ctx.Done():
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// sleep pauses for d and returns false if ctx was canceled in the meantime.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -2,69 +2,74 @@ package scrap
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/jmoiron/sqlx"
 	"go.uber.org/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-
-	"github.com/ory/x/httpx"
 )
 
 type Scraper struct {
 	sync.RWMutex
 
-	c  *http.Client
-	db *sqlx.DB
+	store Store
 
-	blacklist         map[string]bool
+	registries        map[string]Registry
+	inFlight          map[string]struct{}
 	l                 logrus.FieldLogger
-	pageSize          int
+	instance          string
+	leaseDuration     time.Duration
 	taskCount         int
 	discoverEvery     time.Duration
-	delay             time.Duration
 	scrapRefreshQueue time.Duration
 	refreshEvery      time.Duration
 	updateEvery       string
 	scrapEvery        func(time.Time) (time.Time, string)
-	queue             chan string
+	queue             chan Repository
 
-	snapshotsCompleted atomic.Uint64
-	reposDiscovered    atomic.Uint64
+	snapshotsCompleted  atomic.Uint64
+	snapshotsEnqueued   atomic.Uint64
+	snapshotsSuppressed atomic.Uint64
+	reposDiscovered     atomic.Uint64
 }
 
 func NewScraper(
 	tasks int,
 	l logrus.FieldLogger,
-	db *sqlx.DB,
+	store Store,
 	discoverEvery time.Duration,
-	delay time.Duration,
 	scrapRefreshQueue time.Duration,
-	pageSize int,
 	daysRefresh int,
+	instance string,
+	leaseDuration time.Duration,
+	registries ...Registry,
 ) *Scraper {
 	if tasks < 1 {
 		tasks = 1
 	}
 
+	rs := make(map[string]Registry, len(registries))
+	for _, r := range registries {
+		rs[r.Name()] = r
+	}
+
 	return &Scraper{
-		l:  l,
-		db: db,
+		l:          l,
+		store:      instrumentStore(store),
+		registries: rs,
 
 		// defaults
-		queue:             make(chan string, tasks),
+		instance:          instance,
+		leaseDuration:     leaseDuration,
+		inFlight:          make(map[string]struct{}),
+		queue:             make(chan Repository, tasks),
 		taskCount:         tasks,
-		pageSize:          pageSize,
 		discoverEvery:     discoverEvery,
-		delay:             delay,
 		scrapRefreshQueue: scrapRefreshQueue,
 		refreshEvery:      -time.Hour * 24 * time.Duration(daysRefresh),
 		scrapEvery: func(i time.Time) (time.Time, string) {
@@ -73,10 +78,6 @@ func NewScraper(
 			// return time.Date(i.Year(), i.Month(), i.Day(), i.Hour(), i.Minute(), 0, 0, i.Location()), "1 minute"
 			// return time.Date(i.Year(), i.Month(), i.Day(), i.Hour(), i.Minute(), i.Second(), 0, i.Location()), "1 second"
 		},
-		c: &http.Client{
-			Timeout:   time.Second * 30,
-			Transport: httpx.NewDefaultResilientRoundTripper(time.Second*10, time.Second*30),
-		},
 	}
 }
 
@@ -89,29 +90,33 @@ type Stats struct {
 	SnapShotQueue           int64 `json:"snapshot_queue"`
 
 	SnapshotsCompleted      uint64 `json:"proc_snapshots_completed"`
+	SnapshotsEnqueued       uint64 `json:"proc_snapshots_enqueued"`
+	SnapshotsSuppressed     uint64 `json:"proc_snapshots_suppressed"`
+	SnapshotsInFlight       int    `json:"proc_snapshots_in_flight"`
 	DiscoveriesCompleted    uint64 `json:"proc_discoveries_completed"`
 	SnapshotQueueLength     int    `json:"proc_snapshot_queue_length"`
 	SnapshotRefreshInterval string `json:"snapshot_refresh_interval"`
 }
 
 func (i *Scraper) Stats(c context.Context) *Stats {
-	hr, err := i.dbCountHealthyRepos(c)
-	if err != nil {
-		i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Errorf("Unable to count elements")
-	}
-	sq, err := i.dbCountSnapshotQueue(c)
+	tr, hr, err := i.store.CountRepositories(c)
 	if err != nil {
 		i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Errorf("Unable to count elements")
 	}
-	tr, err := i.dbCountTotalRepos(c)
+	sq, err := i.store.CountSnapshotQueue(c, i.due(time.Now().UTC()))
 	if err != nil {
 		i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Errorf("Unable to count elements")
 	}
-	cs, err := i.dbCountSnapshots(c)
+	cs, err := i.store.CountSnapshots(c)
 	if err != nil {
 		i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Errorf("Unable to count elements")
 	}
 	_, interval := i.scrapEvery(time.Now())
+
+	i.RLock()
+	inFlight := len(i.inFlight)
+	i.RUnlock()
+
 	return &Stats{
 		TotalRepositories:         tr,
 		RepositoriesWithoutErrors: hr,
@@ -122,195 +127,353 @@ func (i *Scraper) Stats(c context.Context) *Stats {
 
 		DiscoveriesCompleted:    i.reposDiscovered.Load(),
 		SnapshotsCompleted:      i.snapshotsCompleted.Load(),
+		SnapshotsEnqueued:       i.snapshotsEnqueued.Load(),
+		SnapshotsSuppressed:     i.snapshotsSuppressed.Load(),
+		SnapshotsInFlight:       inFlight,
 		SnapshotRefreshInterval: interval,
 		SnapshotQueueLength:     len(i.queue),
 	}
 }
 
-func (i *Scraper) FindSnapshots(slug string) (RepositorySnapshots, error) {
-	return i.dbListSnapshots(context.Background(), slug, "search")
+// FindSnapshots returns the repository's snapshots fetched between from and
+// to. A zero to means until now.
+func (i *Scraper) FindSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+
+	if to.IsZero() {
+		to = maxDate
+	}
+	return i.store.ListSnapshots(ctx, registry, slug, from, to)
+}
+
+// SearchSnapshots is like FindSnapshots but adds repositories which are not
+// known yet to the snapshot queue and returns no snapshots for them.
+func (i *Scraper) SearchSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error) {
+	snapshots, err := i.FindSnapshots(ctx, registry, slug, from, to)
+	if errors.Is(err, ErrRepositoryNotFound) {
+		i.l.Debugf(`Discovered a new repository in registry "%s" from source "%s": %s`, registry, SourceSearch, slug)
+		return RepositorySnapshots{}, i.store.AddRepositories(ctx, registry, SourceSearch, []string{slug})
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// FindTags returns the current state of the repository's tags.
+func (i *Scraper) FindTags(ctx context.Context, registry, slug string) (RepositoryTags, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+	return i.store.ListTags(ctx, registry, slug)
 }
 
-func (i *Scraper) ListRepositorySlugs(ctx context.Context) ([]string, error) {
-	return i.dbDiscoveryList(ctx)
+// FindTagPushes returns the repository's tag pushes between from and to. A
+// zero to selects all pushes since from.
+func (i *Scraper) FindTagPushes(ctx context.Context, registry, slug string, from, to time.Time) (RepositoryTags, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = maxDate
+	}
+	return i.store.ListTagPushes(ctx, registry, slug, from, to)
 }
 
-func (i *Scraper) Scrap() {
+// WalkSnapshots calls fn for every snapshot selected by q. A zero q.To selects
+// all snapshots since q.From.
+func (i *Scraper) WalkSnapshots(ctx context.Context, q SnapshotQuery, fn func(*SnapshotRecord) error) error {
+	if _, err := i.registry(q.Registry); err != nil {
+		return err
+	}
+
+	if q.To.IsZero() {
+		q.To = maxDate
+	}
+	return i.store.WalkSnapshots(ctx, q, fn)
+}
+
+// FindLatestSnapshot returns the repository's most recent snapshot or nil if
+// it has not been scrapped yet.
+func (i *Scraper) FindLatestSnapshot(ctx context.Context, registry, slug string) (*RepositorySnapshot, error) {
+	if _, err := i.registry(registry); err != nil {
+		return nil, err
+	}
+	return i.store.LatestSnapshot(ctx, registry, slug)
+}
+
+// Leaderboard returns the repositories with the highest pull growth as of the
+// last completed snapshot cycle.
+func (i *Scraper) Leaderboard(ctx context.Context, q LeaderboardQuery) ([]RepositoryGrowth, error) {
+	if _, err := i.registry(q.Registry); err != nil {
+		return nil, err
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return i.store.Leaderboard(ctx, q)
+}
+
+// due returns the time before which repositories must have been scrapped last
+// to be refreshed.
+func (i *Scraper) due(now time.Time) time.Time {
+	return now.Add(i.refreshEvery)
+}
+
+// ListRepositories returns a page of repositories. The page is followed by
+// the repositories after the slug of its last element.
+func (i *Scraper) ListRepositories(ctx context.Context, q RepositoryQuery) ([]Repository, error) {
+	if _, err := i.registry(q.Registry); err != nil {
+		return nil, err
+	}
+	return i.store.ListRepositories(ctx, q)
+}
+
+// Scrap fetches snapshots of repositories which are due for a refresh until
+// ctx is canceled and returns the errors of snapshots which failed while
+// draining.
+func (i *Scraper) Scrap(ctx context.Context) error {
+	work, cancel := withGracePeriod(ctx, drainGracePeriod)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errs errorCollector
 	for t := 0; t < i.taskCount; t++ {
-		go i.watchSnapshotQueue(i.queue)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.watchSnapshotQueue(ctx, work, i.queue, &errs)
+		}()
 	}
 
-	i.discoverNextSnapshotRefresh(i.queue)
+	i.discoverNextSnapshotRefresh(ctx, i.queue)
+	wg.Wait()
+	return errs.err()
 }
 
-func (i *Scraper) watchSnapshotQueue(queue chan string) {
-	for slug := range queue {
-		if err := i.fetchSnapshot(slug); err != nil {
+// watchSnapshotQueue fetches snapshots using the work context so that in-flight
+// snapshots complete when ctx is canceled. Snapshots which are still queued at
+// that point are skipped.
+func (i *Scraper) watchSnapshotQueue(ctx, work context.Context, queue chan Repository, errs *errorCollector) {
+	for repo := range queue {
+		snapshotQueueDepth.Set(float64(len(queue)))
+		if ctx.Err() != nil {
+			if err := i.store.ReleaseRepository(work, repo.Registry, repo.Slug, i.instance); err != nil {
+				errs.add(err)
+			}
+			i.snapshotQueuePop(repo)
+			continue
+		}
+
+		if err := i.fetchSnapshot(work, repo); err != nil {
 			i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Errorf("Unable to scrap repository")
+			if ctx.Err() != nil {
+				errs.add(err)
+			}
 		}
 	}
 }
 
-func (i *Scraper) snapshotQueuePop(slug string) {
+func snapshotQueueKey(repo Repository) string {
+	return repo.Registry + "/" + repo.Slug
+}
+
+// snapshotQueuePop removes the repository from the in-flight set once its
+// snapshot was fetched or skipped.
+func (i *Scraper) snapshotQueuePop(repo Repository) {
 	i.Lock()
-	i.Unlock()
-	delete(i.blacklist, slug)
+	defer i.Unlock()
+	delete(i.inFlight, snapshotQueueKey(repo))
+	snapshotsInFlight.Set(float64(len(i.inFlight)))
+}
 
+// snapshotInFlight returns true if the repository is queued or being fetched.
+func (i *Scraper) snapshotInFlight(repo Repository) bool {
+	i.Lock()
+	defer i.Unlock()
+	_, ok := i.inFlight[snapshotQueueKey(repo)]
+	return ok
 }
 
-func (i *Scraper) snapshotQueuePush(slug string, queue chan string) {
-	i.RLock()
-	_, isInQueue := i.blacklist[slug]
-	i.RUnlock()
+// snapshotQueuePush enqueues the repository unless it is already queued or
+// being fetched. It returns false if ctx was canceled while waiting for a free
+// slot in the queue.
+func (i *Scraper) snapshotQueuePush(ctx context.Context, repo Repository, queue chan Repository) bool {
+	key := snapshotQueueKey(repo)
 
-	if !isInQueue {
-		i.Lock()
-		i.blacklist[slug] = true
+	i.Lock()
+	if _, isInQueue := i.inFlight[key]; isInQueue {
 		i.Unlock()
-		queue <- slug
+		i.snapshotsSuppressed.Inc()
+		return true
+	}
+	i.inFlight[key] = struct{}{}
+	snapshotsInFlight.Set(float64(len(i.inFlight)))
+	i.Unlock()
+
+	select {
+	case queue <- repo:
+		i.snapshotsEnqueued.Inc()
+		snapshotQueueDepth.Set(float64(len(queue)))
+		return true
+	case <-ctx.Done():
+		i.snapshotQueuePop(repo)
+		return false
 	}
 }
 
-func (i *Scraper) discoverNextSnapshotRefresh(queue chan string) {
+// discoverNextSnapshotRefresh enqueues repositories which are due for a
+// snapshot. Once all due repositories have been leased, the snapshot cycle is
+// complete and the growth rollup is refreshed if new snapshots were stored.
+func (i *Scraper) discoverNextSnapshotRefresh(ctx context.Context, queue chan Repository) {
 	defer close(queue)
+
+	var rolledUp uint64
 	for {
-		is, err := i.dbDiscoveryFetchNext(context.Background())
-		if err != nil {
+		now := time.Now().UTC()
+		is, err := i.store.LeaseRepositories(ctx, i.instance, now, i.due(now), now.Add(i.leaseDuration), 500)
+		if err != nil && !isCanceled(err) {
 			i.l.WithError(err).Error("Unable to iterate over repositories")
 		}
 
-		for _, i := range is {
-			queue <- i
+		if err == nil && len(is) == 0 && i.snapshotsCompleted.Load() != rolledUp {
+			rolledUp = i.snapshotsCompleted.Load()
+			i.l.Debugf("Snapshot cycle completed, refreshing growth rollup")
+			if err := i.store.RefreshGrowth(ctx, now); err != nil && !isCanceled(err) {
+				i.l.WithError(err).WithField("stack", fmt.Sprintf("%+v", err)).Error("Unable to refresh growth rollup")
+			}
+		}
+
+		for _, repo := range is {
+			if !i.snapshotQueuePush(ctx, repo, queue) {
+				return
+			}
 		}
 
-		time.Sleep(i.scrapRefreshQueue)
+		if !sleep(ctx, i.scrapRefreshQueue) {
+			return
+		}
 	}
 }
 
-func (i *Scraper) fetchSnapshot(slug string) error {
-	defer i.snapshotQueuePop(slug)
+func (i *Scraper) fetchSnapshot(ctx context.Context, repo Repository) error {
+	defer i.snapshotQueuePop(repo)
 	defer i.snapshotsCompleted.Add(1)
 
-	uri := "https://hub.docker.com/v2/repositories/" + strings.TrimSpace(
-		strings.Trim(
-			slug, "\n",
-		),
-	) + "/"
-
-	i.l.Debugf(`Fetching repository data for "%s" from: %s`, slug, uri)
-	res, err := i.c.Get(uri)
+	registry, err := i.registry(repo.Registry)
 	if err != nil {
-		return errors.Wrapf(err, "repository: %s", slug)
+		return errors.Wrapf(err, "repository: %s", repo.Slug)
 	}
-	defer res.Body.Close()
 
-	if err := checkStatus(res, http.StatusOK); err != nil {
-		if err := i.dbDiscoveryMarkError(context.Background(), slug, res.StatusCode); err != nil {
-			return errors.Wrapf(err, "repository: %s", slug)
-		}
-		return errors.Wrapf(err, "repository: %s", slug)
+	start := time.Now()
+	dr, err := registry.FetchSnapshot(ctx, repo.Slug)
+	since(snapshotFetchDuration, start, repo.Registry)
+	if err != nil {
+		return i.markRepositoryError(ctx, repo, err)
 	}
 
-	var dr RepositorySnapshot
-	if err := json.NewDecoder(res.Body).Decode(&dr); err != nil {
-		return errors.Wrapf(err, "repository: %s", slug)
+	date, _ := i.scrapEvery(time.Now().UTC())
+	if err := i.store.AddSnapshot(ctx, repo.Registry, repo.Slug, dr, date); errors.Is(err, ErrImplausiblePulls) {
+		return i.markRepositoryError(ctx, repo, err)
+	} else if err != nil {
+		return errors.Wrapf(err, "repository: %s", repo.Slug)
 	}
 
-	if err := i.dbSnapshotAdd(context.Background(), slug, &dr); err != nil {
-		return errors.Wrapf(err, "repository: %s", slug)
-	}
+	snapshotsTotal.WithLabelValues(repo.Registry, "success").Inc()
+	i.l.Debugf("Repository data stored successfully for: %s", repo.Slug)
 
-	i.l.Debugf("Repository data stored successfully for: %s", slug)
+	if tr, ok := registry.(TagRegistry); ok {
+		i.fetchTags(ctx, tr, repo)
+	}
 
 	return nil
 }
 
-func (i *Scraper) Discover() {
-	uris := []string{
-		fmt.Sprintf("https://hub.docker.com/api/content/v1/products/search?q=&type=image&page_size=%d", i.pageSize),
-		fmt.Sprintf("https://hub.docker.com/api/content/v1/products/search?sort=updated_at&order=desc&type=image&page_size=%d", i.pageSize),
-	}
-
-	for {
-		for _, uri := range uris {
-			i.l.Debugf("Discovering repositories from: %s", uri)
-			if err := i.discover(uri); err != nil {
-				i.l.WithError(err).Errorf("An error occurred during repository discovery.")
-			}
-			i.l.Debugf("Discovery finished for: %s", uri)
+// markRepositoryError records why the repository's snapshot failed so that it
+// is retried or excluded, and returns err.
+func (i *Scraper) markRepositoryError(ctx context.Context, repo Repository, err error) error {
+	if !isCanceled(err) {
+		kind, code := classifyError(err)
+		snapshotsTotal.WithLabelValues(repo.Registry, string(kind)).Inc()
+		if err := i.store.MarkRepositoryError(ctx, repo.Registry, repo.Slug, kind, code, time.Now().UTC()); err != nil {
+			return errors.Wrapf(err, "repository: %s", repo.Slug)
 		}
-		i.l.Debugf("Discovery finished for all sources, going to sleep for %.2fm", i.discoverEvery)
-		time.Sleep(i.discoverEvery)
 	}
+	return errors.Wrapf(err, "repository: %s", repo.Slug)
 }
 
-func (i *Scraper) discover(next string) error {
-	for len(next) > 0 {
-		i.l.Debugf("Discovering next uri: %s", next)
-		result, err := i.fetchDiscovery(next)
-		if err != nil {
-			return errors.Wrapf(err, `discover: "%s"`, next)
-		}
-
-		slugs := make([]string, len(result.Summaries))
-		for k, r := range result.Summaries {
-			slugs[k] = r.Slug
-		}
-
-		i.l.Debugf("Updating repository index based on uri result: %s", next)
-		if err := i.dbDiscoveryBatch(context.Background(), "discovery", slugs); err != nil {
-			return errors.Wrapf(err, `discover: "%s"`, next)
-		}
-		i.l.Debugf("Repository index update done!")
-
-		next = result.Next
-		i.l.Debugf("Going to sleep for %.2fs before discovering next uri: %s", i.delay.Seconds(), next)
-		time.Sleep(i.delay)
+// fetchTags stores the repository's tags. Errors are only logged because the
+// snapshot has been stored already.
+func (i *Scraper) fetchTags(ctx context.Context, registry TagRegistry, repo Repository) {
+	tags, err := registry.FetchTags(ctx, repo.Slug)
+	if err == nil && len(tags) > 0 {
+		err = i.store.AddTags(ctx, repo.Registry, repo.Slug, tags, time.Now().UTC())
+	}
+	if isCanceled(err) {
+		return
+	} else if err != nil {
+		tagFetchesTotal.WithLabelValues(repo.Registry, "error").Inc()
+		i.l.WithError(err).Warnf("Unable to fetch tags of repository: %s", repo.Slug)
+		return
 	}
 
-	return nil
+	if len(tags) > 0 {
+		tagFetchesTotal.WithLabelValues(repo.Registry, "success").Inc()
+		i.l.Debugf("Stored %d tags of repository: %s", len(tags), repo.Slug)
+	}
 }
 
-func (i *Scraper) fetchDiscovery(uri string) (*discoveryResult, error) {
-	defer i.reposDiscovered.Add(1)
-
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, errors.Wrapf(err, "discovery: %s", uri)
+// Discover crawls all registries for new repositories every discoverEvery until
+// ctx is canceled.
+func (i *Scraper) Discover(ctx context.Context) error {
+	for {
+		for _, r := range i.registries {
+			i.l.Debugf("Discovering repositories in registry: %s", r.Name())
+			if err := r.Discover(ctx, func(slugs []string) error {
+				defer i.reposDiscovered.Add(1)
+				discoveryPagesTotal.WithLabelValues(r.Name()).Inc()
+				i.l.Debugf(`Persisting %d discovered repositories from registry "%s"`, len(slugs), r.Name())
+				return i.store.AddRepositories(ctx, r.Name(), SourceDiscovery, slugs)
+			}); err != nil && !isCanceled(err) {
+				i.l.WithError(err).Errorf("An error occurred during repository discovery.")
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			i.l.Debugf("Discovery finished for registry: %s", r.Name())
+		}
+		i.l.Debugf("Discovery finished for all sources, going to sleep for %.2fm", i.discoverEvery.Minutes())
+		if !sleep(ctx, i.discoverEvery) {
+			return nil
+		}
 	}
-	req.Header.Set("Search-Version", "v3")
+}
 
-	res, err := i.c.Do(req)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-	defer res.Body.Close()
-	if err := checkStatus(res, http.StatusOK); err != nil {
-		return nil, errors.Wrapf(err, "discovery: %s", uri)
-	}
+type statusError struct {
+	expected int
+	code     int
+	body     []byte
+}
 
-	var s discoveryResult
-	if err := json.NewDecoder(res.Body).Decode(&s); err != nil {
-		return nil, errors.Wrapf(err, "discovery: %s", uri)
-	}
+func (e *statusError) Error() string {
+	return fmt.Sprintf("http: expected status code %d but got %d with body: %s", e.expected, e.code, e.body)
+}
 
-	for k, repo := range s.Summaries {
-		if !strings.Contains(repo.Slug, "/") {
-			s.Summaries[k].Slug = strings.TrimSpace(
-				strings.Trim(
-					"library/"+repo.Slug, "\n",
-				),
-			)
-		}
+// statusCode returns the unexpected HTTP status code wrapped by err or 0 if err
+// was not caused by an unexpected status code.
+func statusCode(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code
 	}
-
-	return &s, nil
+	return 0
 }
 
 func checkStatus(res *http.Response, expected int) error {
 	if res.StatusCode != expected {
 		body, _ := ioutil.ReadAll(res.Body)
-		return errors.Errorf("http: expected status code %d but got %d with body: %s", expected, res.StatusCode, body)
+		return errors.WithStack(&statusError{expected: expected, code: res.StatusCode, body: body})
 	}
 	return nil
 }
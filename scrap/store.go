@@ -0,0 +1,220 @@
+package scrap
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrRepositoryNotFound = errors.New("repository has not been discovered yet")
+
+var ErrRepositoryLeased = errors.New("repository is leased by another instance")
+
+var (
+	zeroDate = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDate  = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+)
+
+type RepositorySnapshots []*RepositorySnapshot
+
+// RepositoryStatus filters repositories by their error state.
+type RepositoryStatus string
+
+const (
+	RepositoryStatusAll RepositoryStatus = "all"
+	// RepositoryStatusHealthy selects repositories without errors.
+	RepositoryStatusHealthy RepositoryStatus = "healthy"
+	// RepositoryStatusRetrying selects repositories waiting to be retried
+	// after a transient error.
+	RepositoryStatusRetrying RepositoryStatus = "retrying"
+	// RepositoryStatusExcluded selects repositories excluded by a permanent error.
+	RepositoryStatusExcluded RepositoryStatus = "excluded"
+)
+
+var ErrUnknownRepositoryStatus = errors.New("status must be one of all, healthy, retrying or excluded")
+
+func ParseRepositoryStatus(s string) (RepositoryStatus, error) {
+	switch st := RepositoryStatus(s); st {
+	case RepositoryStatusAll, RepositoryStatusHealthy, RepositoryStatusRetrying, RepositoryStatusExcluded:
+		return st, nil
+	}
+	return "", errors.WithStack(ErrUnknownRepositoryStatus)
+}
+
+func (s RepositoryStatus) matches(r *Repository) bool {
+	return s == RepositoryStatusAll || s == "" || s == r.Status()
+}
+
+// Status returns whether the repository is healthy, retrying or excluded.
+func (r *Repository) Status() RepositoryStatus {
+	switch {
+	case r.ErrorCode != 0:
+		return RepositoryStatusExcluded
+	case r.ErrorKind != ErrorKindNone:
+		return RepositoryStatusRetrying
+	}
+	return RepositoryStatusHealthy
+}
+
+// RepositoryQuery selects repositories ordered by slug.
+type RepositoryQuery struct {
+	Registry string
+	// Prefix only selects slugs starting with it, for example "library/".
+	Prefix string
+	// Search only selects slugs containing it.
+	Search string
+	// Slugs only selects these slugs if set.
+	Slugs []string
+	// Source only selects repositories discovered by it if set.
+	Source string
+	// Status defaults to all repositories.
+	Status RepositoryStatus
+	// After only selects slugs following it, used as pagination cursor.
+	After string
+	Limit int
+}
+
+// SnapshotQuery selects the snapshots of many repositories.
+type SnapshotQuery struct {
+	Registry string
+	// Slugs selects these repositories if set, otherwise all repositories
+	// whose slug starts with Prefix.
+	Slugs  []string
+	Prefix string
+	// From and To select snapshots fetched in between (inclusive).
+	From, To time.Time
+}
+
+func (q SnapshotQuery) matches(registry, slug string) bool {
+	if registry != q.Registry {
+		return false
+	} else if len(q.Slugs) == 0 {
+		return strings.HasPrefix(slug, q.Prefix)
+	}
+	return containsSlug(q.Slugs, slug)
+}
+
+func containsSlug(slugs []string, slug string) bool {
+	for _, s := range slugs {
+		if s == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapshotRecord is a snapshot together with the repository it belongs to.
+type SnapshotRecord struct {
+	Registry string `json:"registry" db:"registry"`
+	Slug     string `json:"slug" db:"slug"`
+	RepositorySnapshot
+}
+
+// Store persists repositories, their snapshots and the snapshot queue.
+type Store interface {
+	// CountRepositories returns the number of all repositories and of those
+	// which are not excluded by a permanent error.
+	CountRepositories(ctx context.Context) (total, healthy int64, err error)
+
+	// CountSnapshots returns the number of all snapshots.
+	CountSnapshots(ctx context.Context) (int64, error)
+
+	// CountSnapshotQueue returns the number of healthy repositories which were
+	// last scrapped before due.
+	CountSnapshotQueue(ctx context.Context, due time.Time) (int64, error)
+
+	// ListSnapshots returns the repository's snapshots fetched between from
+	// and to (inclusive) in chronological order or ErrRepositoryNotFound.
+	ListSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error)
+
+	// LatestSnapshot returns the repository's most recent snapshot, nil if it
+	// has none yet, or ErrRepositoryNotFound.
+	LatestSnapshot(ctx context.Context, registry, slug string) (*RepositorySnapshot, error)
+
+	// WalkSnapshots calls fn for every snapshot selected by q ordered by slug
+	// and time without loading all of them into memory. fn must not use the
+	// store.
+	WalkSnapshots(ctx context.Context, q SnapshotQuery, fn func(*SnapshotRecord) error) error
+
+	// ImportSnapshots stores the snapshots as they are, skipping those whose
+	// timestamp is already stored for the repository, and returns the number of
+	// stored snapshots or ErrRepositoryNotFound.
+	ImportSnapshots(ctx context.Context, registry, slug string, snapshots RepositorySnapshots) (int, error)
+
+	// AddSnapshot stores the snapshot, marks the repository as scrapped at
+	// scrappedAt and clears its lease and transient errors. r.Pulls is the
+	// pull count reported by the registry, which is kept in r.PullsRaw and
+	// replaced by the count corrected for int32 wraparounds. Snapshots whose
+	// count can not be corrected are rejected with ErrImplausiblePulls.
+	AddSnapshot(ctx context.Context, registry, slug string, r *RepositorySnapshot, scrappedAt time.Time) error
+
+	// AddTags stores the current state of the tags and records every digest
+	// which was not stored for a tag yet as a push. Tags which are not passed
+	// are kept.
+	AddTags(ctx context.Context, registry, slug string, tags RepositoryTags, fetchedAt time.Time) error
+
+	// ListTags returns the current state of the repository's tags ordered by
+	// their last push or ErrRepositoryNotFound.
+	ListTags(ctx context.Context, registry, slug string) (RepositoryTags, error)
+
+	// ListTagPushes returns the repository's tag pushes between from and to
+	// (inclusive) in chronological order or ErrRepositoryNotFound.
+	ListTagPushes(ctx context.Context, registry, slug string, from, to time.Time) (RepositoryTags, error)
+
+	// CorrectPulls recomputes the corrected pull counts of the repository's
+	// snapshots from their raw counts and returns the number of updated
+	// snapshots or ErrRepositoryNotFound.
+	CorrectPulls(ctx context.Context, registry, slug string) (int, error)
+
+	// ListRepositories returns the repositories selected by q.
+	ListRepositories(ctx context.Context, q RepositoryQuery) ([]Repository, error)
+
+	// AddRepositories adds the slugs which are not known yet.
+	AddRepositories(ctx context.Context, registry, source string, slugs []string) error
+
+	// ImportRepository adds the repository if it is not known yet. Otherwise
+	// its scrap state is replaced by that of r if r was scrapped more
+	// recently. The error and retry state of r is only imported if
+	// withErrors is set. Leases are never imported.
+	ImportRepository(ctx context.Context, r *Repository, withErrors bool) error
+
+	// RemoveRepository deletes the repository with its snapshots, tags and
+	// growth or returns ErrRepositoryNotFound.
+	RemoveRepository(ctx context.Context, registry, slug string) error
+
+	// LeaseRepositories leases up to limit healthy repositories which were
+	// last scrapped before due and are neither leased nor waiting for a retry
+	// at now to owner until leasedUntil.
+	LeaseRepositories(ctx context.Context, owner string, now, due, leasedUntil time.Time, limit int) ([]Repository, error)
+
+	// LeaseRepository leases the repository to owner until leasedUntil
+	// regardless of when it was scrapped and of its errors. It returns
+	// ErrRepositoryLeased if another owner holds a lease at now, or
+	// ErrRepositoryNotFound.
+	LeaseRepository(ctx context.Context, registry, slug, owner string, now, leasedUntil time.Time) (*Repository, error)
+
+	// ReleaseRepository gives up owner's lease of the repository.
+	ReleaseRepository(ctx context.Context, registry, slug, owner string) error
+
+	// RefreshGrowth recomputes the pull growth rollup of every repository for
+	// all LeaderboardWindows ending at now.
+	RefreshGrowth(ctx context.Context, now time.Time) error
+
+	// Leaderboard returns the repositories with the highest pull growth from
+	// the rollup.
+	Leaderboard(ctx context.Context, q LeaderboardQuery) ([]RepositoryGrowth, error)
+
+	// ListGrowth returns the rollup of all repositories whose slug starts
+	// with prefix.
+	ListGrowth(ctx context.Context, registry string, window int, prefix string) ([]RepositoryGrowth, error)
+
+	// SumGrowth returns the summed pull growth of all repositories whose slug
+	// starts with prefix.
+	SumGrowth(ctx context.Context, registry string, window int, prefix string) (int64, error)
+
+	// MarkRepositoryError excludes the repository if kind is permanent and
+	// otherwise schedules a retry with exponential backoff.
+	MarkRepositoryError(ctx context.Context, registry, slug string, kind ErrorKind, code int, at time.Time) error
+}
@@ -0,0 +1,575 @@
+package scrap
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryStore keeps everything in memory. It is meant for tests and for trying
+// out dockerstats without a database.
+type MemoryStore struct {
+	sync.RWMutex
+
+	repositories []*Repository
+	repositoryID int
+	snapshots    map[int]RepositorySnapshots
+	snapshotID   int
+	growth       map[int][]RepositoryGrowth
+	tags         map[int]map[string]*RepositoryTag
+	tagPushes    map[int]RepositoryTags
+}
+
+var _ Store = new(MemoryStore)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: make(map[int]RepositorySnapshots),
+		growth:    make(map[int][]RepositoryGrowth),
+		tags:      make(map[int]map[string]*RepositoryTag),
+		tagPushes: make(map[int]RepositoryTags),
+	}
+}
+
+func (s *MemoryStore) find(registry, slug string) (*Repository, error) {
+	for _, r := range s.repositories {
+		if r.Registry == registry && r.Slug == slug {
+			return r, nil
+		}
+	}
+	return nil, errors.WithStack(ErrRepositoryNotFound)
+}
+
+func (s *MemoryStore) CountRepositories(ctx context.Context) (total, healthy int64, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, r := range s.repositories {
+		if r.ErrorCode == 0 {
+			healthy++
+		}
+	}
+	return int64(len(s.repositories)), healthy, nil
+}
+
+func (s *MemoryStore) CountSnapshots(ctx context.Context) (int64, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var count int64
+	for _, snapshots := range s.snapshots {
+		count += int64(len(snapshots))
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) CountSnapshotQueue(ctx context.Context, due time.Time) (int64, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var count int64
+	for _, r := range s.repositories {
+		if r.ErrorCode == 0 && r.LastScrappedAt.Before(due) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) ListSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := RepositorySnapshots{}
+	for _, snapshot := range s.snapshots[r.ID] {
+		if snapshot.Timestamp.Before(from) || snapshot.Timestamp.After(to) {
+			continue
+		}
+		c := *snapshot
+		snapshots = append(snapshots, &c)
+	}
+	return snapshots, nil
+}
+
+func (s *MemoryStore) LatestSnapshot(ctx context.Context, registry, slug string) (*RepositorySnapshot, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := s.snapshots[r.ID]
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	c := *snapshots[len(snapshots)-1]
+	return &c, nil
+}
+
+func (s *MemoryStore) WalkSnapshots(ctx context.Context, q SnapshotQuery, fn func(*SnapshotRecord) error) error {
+	// The records are copied so that fn is called without holding the lock.
+	var records []*SnapshotRecord
+	s.RLock()
+	for _, r := range s.repositories {
+		if !q.matches(r.Registry, r.Slug) {
+			continue
+		}
+		for _, snapshot := range s.snapshots[r.ID] {
+			if snapshot.Timestamp.Before(q.From) || snapshot.Timestamp.After(q.To) {
+				continue
+			}
+			records = append(records, &SnapshotRecord{Registry: r.Registry, Slug: r.Slug, RepositorySnapshot: *snapshot})
+		}
+	}
+	s.RUnlock()
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Slug < records[j].Slug
+	})
+	for _, r := range records {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ImportSnapshots(ctx context.Context, registry, slug string, snapshots RepositorySnapshots) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return 0, err
+	}
+
+	var imported int
+	for _, snapshot := range snapshots {
+		exists := false
+		for _, stored := range s.snapshots[r.ID] {
+			if stored.Timestamp.Equal(snapshot.Timestamp) {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+
+		s.snapshotID++
+		c := *snapshot
+		c.ID = s.snapshotID
+		c.RepositoryID = r.ID
+		s.snapshots[r.ID] = append(s.snapshots[r.ID], &c)
+		imported++
+	}
+
+	sort.SliceStable(s.snapshots[r.ID], func(i, j int) bool {
+		return s.snapshots[r.ID][i].Timestamp.Before(s.snapshots[r.ID][j].Timestamp)
+	})
+	return imported, nil
+}
+
+func (s *MemoryStore) AddSnapshot(ctx context.Context, registry, slug string, snapshot *RepositorySnapshot, scrappedAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return err
+	}
+
+	var previous *RepositorySnapshot
+	if snapshots := s.snapshots[r.ID]; len(snapshots) > 0 {
+		previous = snapshots[len(snapshots)-1]
+	}
+
+	snapshot.PullsRaw = snapshot.Pulls
+	if snapshot.Pulls, err = correctPulls(snapshot.PullsRaw, previous); err != nil {
+		return err
+	}
+
+	s.snapshotID++
+	snapshot.ID = s.snapshotID
+	snapshot.Timestamp = time.Now().UTC()
+	snapshot.RepositoryID = r.ID
+
+	c := *snapshot
+	s.snapshots[r.ID] = append(s.snapshots[r.ID], &c)
+
+	r.LastScrappedAt = scrappedAt
+	r.ErrorKind = ErrorKindNone
+	r.RetryCount = 0
+	r.LeasedBy = ""
+	r.LeasedUntil = zeroDate
+	return nil
+}
+
+func (s *MemoryStore) AddTags(ctx context.Context, registry, slug string, tags RepositoryTags, fetchedAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return err
+	}
+
+	if s.tags[r.ID] == nil {
+		s.tags[r.ID] = make(map[string]*RepositoryTag)
+	}
+	for _, tag := range tags {
+		c := *tag
+		c.RepositoryID = r.ID
+		c.FetchedAt = fetchedAt
+		s.tags[r.ID][c.Name] = &c
+
+		pushed := false
+		for _, push := range s.tagPushes[r.ID] {
+			if push.Name == c.Name && push.Digest == c.Digest {
+				pushed = true
+				break
+			}
+		}
+		if !pushed {
+			p := c
+			s.tagPushes[r.ID] = append(s.tagPushes[r.ID], &p)
+		}
+	}
+
+	sortTags(s.tagPushes[r.ID])
+	return nil
+}
+
+func (s *MemoryStore) ListTags(ctx context.Context, registry, slug string) (RepositoryTags, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := RepositoryTags{}
+	for _, tag := range s.tags[r.ID] {
+		c := *tag
+		tags = append(tags, &c)
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+func (s *MemoryStore) ListTagPushes(ctx context.Context, registry, slug string, from, to time.Time) (RepositoryTags, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	pushes := RepositoryTags{}
+	for _, push := range s.tagPushes[r.ID] {
+		if push.LastPushed.Before(from) || push.LastPushed.After(to) {
+			continue
+		}
+		c := *push
+		pushes = append(pushes, &c)
+	}
+	return pushes, nil
+}
+
+func (s *MemoryStore) CorrectPulls(ctx context.Context, registry, slug string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return 0, err
+	}
+	return len(correctHistory(s.snapshots[r.ID])), nil
+}
+
+func (s *MemoryStore) AddRepositories(ctx context.Context, registry, source string, slugs []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, slug := range slugs {
+		if _, err := s.find(registry, slug); err == nil {
+			continue
+		}
+
+		r := newRepository(registry, source, slug)
+		s.repositoryID++
+		r.ID = s.repositoryID
+		s.repositories = append(s.repositories, r)
+	}
+	return nil
+}
+
+func (s *MemoryStore) ImportRepository(ctx context.Context, r *Repository, withErrors bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, err := s.find(r.Registry, r.Slug)
+	if err != nil {
+		imported := importedRepository(r, withErrors)
+		s.repositoryID++
+		imported.ID = s.repositoryID
+		s.repositories = append(s.repositories, imported)
+		return nil
+	}
+
+	if stored.LastScrappedAt.Before(r.LastScrappedAt) {
+		stored.LastScrappedAt = r.LastScrappedAt
+		if withErrors {
+			stored.ErrorCode, stored.ErrorKind, stored.ErrorAt = r.ErrorCode, r.ErrorKind, r.ErrorAt
+			stored.RetryCount, stored.RetryAt = r.RetryCount, r.RetryAt
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) RemoveRepository(ctx context.Context, registry, slug string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return err
+	}
+
+	repositories := s.repositories[:0]
+	for _, stored := range s.repositories {
+		if stored != r {
+			repositories = append(repositories, stored)
+		}
+	}
+	s.repositories = repositories
+	delete(s.snapshots, r.ID)
+	delete(s.tags, r.ID)
+	delete(s.tagPushes, r.ID)
+
+	for window, growth := range s.growth {
+		kept := growth[:0]
+		for _, g := range growth {
+			if g.Registry != registry || g.Slug != slug {
+				kept = append(kept, g)
+			}
+		}
+		s.growth[window] = kept
+	}
+	return nil
+}
+
+func (s *MemoryStore) LeaseRepositories(ctx context.Context, owner string, now, due, leasedUntil time.Time, limit int) ([]Repository, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var candidates []*Repository
+	for _, r := range s.repositories {
+		if r.LastScrappedAt.Before(due) && r.ErrorCode == 0 && !r.RetryAt.After(now) && r.LeasedUntil.Before(now) {
+			candidates = append(candidates, r)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].LastScrappedAt.Equal(candidates[j].LastScrappedAt) {
+			return candidates[i].ID < candidates[j].ID
+		}
+		return candidates[i].LastScrappedAt.Before(candidates[j].LastScrappedAt)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	repositories := make([]Repository, len(candidates))
+	for k, r := range candidates {
+		r.LeasedBy = owner
+		r.LeasedUntil = leasedUntil
+		repositories[k] = *r
+	}
+	return repositories, nil
+}
+
+func (s *MemoryStore) LeaseRepository(ctx context.Context, registry, slug, owner string, now, leasedUntil time.Time) (*Repository, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return nil, err
+	}
+	if r.LeasedBy != owner && !r.LeasedUntil.Before(now) {
+		return nil, errors.WithStack(ErrRepositoryLeased)
+	}
+
+	r.LeasedBy = owner
+	r.LeasedUntil = leasedUntil
+	leased := *r
+	return &leased, nil
+}
+
+func (s *MemoryStore) ReleaseRepository(ctx context.Context, registry, slug, owner string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return err
+	}
+
+	if r.LeasedBy == owner {
+		r.LeasedBy = ""
+		r.LeasedUntil = zeroDate
+	}
+	return nil
+}
+
+func (s *MemoryStore) MarkRepositoryError(ctx context.Context, registry, slug string, kind ErrorKind, code int, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.find(registry, slug)
+	if err != nil {
+		return err
+	}
+
+	r.ErrorKind = kind
+	r.ErrorAt = at
+	r.LeasedBy = ""
+	r.LeasedUntil = zeroDate
+	if kind.Permanent() {
+		r.ErrorCode = code
+		return nil
+	}
+
+	r.RetryCount++
+	r.RetryAt = at.Add(retryBackoff(r.RetryCount))
+	return nil
+}
+
+func (s *MemoryStore) RefreshGrowth(ctx context.Context, now time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, window := range LeaderboardWindows {
+		start := now.AddDate(0, 0, -window)
+
+		var growth []RepositoryGrowth
+		for _, r := range s.repositories {
+			snapshots := s.snapshots[r.ID]
+			if len(snapshots) == 0 {
+				continue
+			}
+
+			var baseline *RepositorySnapshot
+			for _, snapshot := range snapshots {
+				if snapshot.Timestamp.After(start) {
+					break
+				}
+				baseline = snapshot
+			}
+			if baseline == nil {
+				continue
+			}
+
+			g := newRepositoryGrowth(window, baseline, snapshots[len(snapshots)-1], now)
+			g.Registry, g.Slug = r.Registry, r.Slug
+			growth = append(growth, g)
+		}
+		s.growth[window] = growth
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Leaderboard(ctx context.Context, q LeaderboardQuery) ([]RepositoryGrowth, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	leaderboard := []RepositoryGrowth{}
+	for _, g := range s.growth[q.Window] {
+		if g.Registry == q.Registry && g.Pulls >= q.MinPulls {
+			leaderboard = append(leaderboard, g)
+		}
+	}
+
+	sort.SliceStable(leaderboard, func(i, j int) bool {
+		if q.Order == LeaderboardOrderRelative {
+			return leaderboard[i].PullsGrowthRelative > leaderboard[j].PullsGrowthRelative
+		}
+		return leaderboard[i].PullsGrowth > leaderboard[j].PullsGrowth
+	})
+	if len(leaderboard) > q.Limit {
+		leaderboard = leaderboard[:q.Limit]
+	}
+	return leaderboard, nil
+}
+
+func (s *MemoryStore) ListRepositories(ctx context.Context, q RepositoryQuery) ([]Repository, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	repositories := []Repository{}
+	for _, r := range s.repositories {
+		if r.Registry != q.Registry ||
+			!strings.HasPrefix(r.Slug, q.Prefix) ||
+			(len(q.Slugs) > 0 && !containsSlug(q.Slugs, r.Slug)) ||
+			!strings.Contains(r.Slug, q.Search) ||
+			(q.Source != "" && r.Source != q.Source) ||
+			!q.Status.matches(r) ||
+			r.Slug <= q.After {
+			continue
+		}
+		repositories = append(repositories, *r)
+	}
+
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].Slug < repositories[j].Slug
+	})
+	if len(repositories) > q.Limit {
+		repositories = repositories[:q.Limit]
+	}
+	return repositories, nil
+}
+
+func (s *MemoryStore) ListGrowth(ctx context.Context, registry string, window int, prefix string) ([]RepositoryGrowth, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	growth := []RepositoryGrowth{}
+	for _, g := range s.growth[window] {
+		if g.Registry == registry && strings.HasPrefix(g.Slug, prefix) {
+			growth = append(growth, g)
+		}
+	}
+
+	sort.Slice(growth, func(i, j int) bool {
+		return growth[i].Slug < growth[j].Slug
+	})
+	return growth, nil
+}
+
+func (s *MemoryStore) SumGrowth(ctx context.Context, registry string, window int, prefix string) (int64, error) {
+	growth, err := s.ListGrowth(ctx, registry, window, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var sum int64
+	for _, g := range growth {
+		sum += g.PullsGrowth
+	}
+	return sum, nil
+}
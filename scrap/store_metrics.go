@@ -0,0 +1,140 @@
+package scrap
+
+import (
+	"context"
+	"time"
+)
+
+// instrumentedStore records the duration of every store operation.
+type instrumentedStore struct {
+	s Store
+}
+
+var _ Store = new(instrumentedStore)
+
+func instrumentStore(s Store) Store {
+	if _, ok := s.(*instrumentedStore); ok {
+		return s
+	}
+	return &instrumentedStore{s: s}
+}
+
+func (s *instrumentedStore) CountRepositories(ctx context.Context) (total, healthy int64, err error) {
+	defer since(storeQueryDuration, time.Now(), "count_repositories")
+	return s.s.CountRepositories(ctx)
+}
+
+func (s *instrumentedStore) CountSnapshots(ctx context.Context) (int64, error) {
+	defer since(storeQueryDuration, time.Now(), "count_snapshots")
+	return s.s.CountSnapshots(ctx)
+}
+
+func (s *instrumentedStore) CountSnapshotQueue(ctx context.Context, due time.Time) (int64, error) {
+	defer since(storeQueryDuration, time.Now(), "count_snapshot_queue")
+	return s.s.CountSnapshotQueue(ctx, due)
+}
+
+func (s *instrumentedStore) ListSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error) {
+	defer since(storeQueryDuration, time.Now(), "list_snapshots")
+	return s.s.ListSnapshots(ctx, registry, slug, from, to)
+}
+
+func (s *instrumentedStore) LatestSnapshot(ctx context.Context, registry, slug string) (*RepositorySnapshot, error) {
+	defer since(storeQueryDuration, time.Now(), "latest_snapshot")
+	return s.s.LatestSnapshot(ctx, registry, slug)
+}
+
+func (s *instrumentedStore) WalkSnapshots(ctx context.Context, q SnapshotQuery, fn func(*SnapshotRecord) error) error {
+	defer since(storeQueryDuration, time.Now(), "walk_snapshots")
+	return s.s.WalkSnapshots(ctx, q, fn)
+}
+
+func (s *instrumentedStore) ImportSnapshots(ctx context.Context, registry, slug string, snapshots RepositorySnapshots) (int, error) {
+	defer since(storeQueryDuration, time.Now(), "import_snapshots")
+	return s.s.ImportSnapshots(ctx, registry, slug, snapshots)
+}
+
+func (s *instrumentedStore) AddSnapshot(ctx context.Context, registry, slug string, r *RepositorySnapshot, scrappedAt time.Time) error {
+	defer since(storeQueryDuration, time.Now(), "add_snapshot")
+	return s.s.AddSnapshot(ctx, registry, slug, r, scrappedAt)
+}
+
+func (s *instrumentedStore) ListRepositories(ctx context.Context, q RepositoryQuery) ([]Repository, error) {
+	defer since(storeQueryDuration, time.Now(), "list_repositories")
+	return s.s.ListRepositories(ctx, q)
+}
+
+func (s *instrumentedStore) AddRepositories(ctx context.Context, registry, source string, slugs []string) error {
+	defer since(storeQueryDuration, time.Now(), "add_repositories")
+	return s.s.AddRepositories(ctx, registry, source, slugs)
+}
+
+func (s *instrumentedStore) AddTags(ctx context.Context, registry, slug string, tags RepositoryTags, fetchedAt time.Time) error {
+	defer since(storeQueryDuration, time.Now(), "add_tags")
+	return s.s.AddTags(ctx, registry, slug, tags, fetchedAt)
+}
+
+func (s *instrumentedStore) ListTags(ctx context.Context, registry, slug string) (RepositoryTags, error) {
+	defer since(storeQueryDuration, time.Now(), "list_tags")
+	return s.s.ListTags(ctx, registry, slug)
+}
+
+func (s *instrumentedStore) ListTagPushes(ctx context.Context, registry, slug string, from, to time.Time) (RepositoryTags, error) {
+	defer since(storeQueryDuration, time.Now(), "list_tag_pushes")
+	return s.s.ListTagPushes(ctx, registry, slug, from, to)
+}
+
+func (s *instrumentedStore) CorrectPulls(ctx context.Context, registry, slug string) (int, error) {
+	defer since(storeQueryDuration, time.Now(), "correct_pulls")
+	return s.s.CorrectPulls(ctx, registry, slug)
+}
+
+func (s *instrumentedStore) ImportRepository(ctx context.Context, r *Repository, withErrors bool) error {
+	defer since(storeQueryDuration, time.Now(), "import_repository")
+	return s.s.ImportRepository(ctx, r, withErrors)
+}
+
+func (s *instrumentedStore) RemoveRepository(ctx context.Context, registry, slug string) error {
+	defer since(storeQueryDuration, time.Now(), "remove_repository")
+	return s.s.RemoveRepository(ctx, registry, slug)
+}
+
+func (s *instrumentedStore) LeaseRepositories(ctx context.Context, owner string, now, due, leasedUntil time.Time, limit int) ([]Repository, error) {
+	defer since(storeQueryDuration, time.Now(), "lease_repositories")
+	return s.s.LeaseRepositories(ctx, owner, now, due, leasedUntil, limit)
+}
+
+func (s *instrumentedStore) LeaseRepository(ctx context.Context, registry, slug, owner string, now, leasedUntil time.Time) (*Repository, error) {
+	defer since(storeQueryDuration, time.Now(), "lease_repository")
+	return s.s.LeaseRepository(ctx, registry, slug, owner, now, leasedUntil)
+}
+
+func (s *instrumentedStore) ReleaseRepository(ctx context.Context, registry, slug, owner string) error {
+	defer since(storeQueryDuration, time.Now(), "release_repository")
+	return s.s.ReleaseRepository(ctx, registry, slug, owner)
+}
+
+func (s *instrumentedStore) RefreshGrowth(ctx context.Context, now time.Time) error {
+	defer since(storeQueryDuration, time.Now(), "refresh_growth")
+	return s.s.RefreshGrowth(ctx, now)
+}
+
+func (s *instrumentedStore) Leaderboard(ctx context.Context, q LeaderboardQuery) ([]RepositoryGrowth, error) {
+	defer since(storeQueryDuration, time.Now(), "leaderboard")
+	return s.s.Leaderboard(ctx, q)
+}
+
+func (s *instrumentedStore) ListGrowth(ctx context.Context, registry string, window int, prefix string) ([]RepositoryGrowth, error) {
+	defer since(storeQueryDuration, time.Now(), "list_growth")
+	return s.s.ListGrowth(ctx, registry, window, prefix)
+}
+
+func (s *instrumentedStore) SumGrowth(ctx context.Context, registry string, window int, prefix string) (int64, error) {
+	defer since(storeQueryDuration, time.Now(), "sum_growth")
+	return s.s.SumGrowth(ctx, registry, window, prefix)
+}
+
+func (s *instrumentedStore) MarkRepositoryError(ctx context.Context, registry, slug string, kind ErrorKind, code int, at time.Time) error {
+	defer since(storeQueryDuration, time.Now(), "mark_repository_error")
+	return s.s.MarkRepositoryError(ctx, registry, slug, kind, code, at)
+}
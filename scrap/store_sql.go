@@ -0,0 +1,660 @@
+package scrap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/sqlxx"
+)
+
+var (
+	snapshotInsertColumns, snapshotInsertArguments = sqlxx.NamedInsertArguments(new(RepositorySnapshot), "id")
+	// snapshotUpdateStatements                       = sqlxx.NamedUpdateArguments(new(RepositorySnapshot))
+
+	repositoryInsertColumns, repositoryInsertArguments = sqlxx.NamedInsertArguments(new(Repository), "id")
+	// repositoryUpdateStatements                         = sqlxx.NamedUpdateArguments(new(Repository))
+)
+
+// SQLStore stores data in PostgreSQL or SQLite. The schemas live in
+// migrations/postgres and migrations/sqlite respectively.
+type SQLStore struct {
+	db *sqlx.DB
+
+	// skipLocked is set for databases which support SELECT ... FOR UPDATE SKIP LOCKED.
+	skipLocked bool
+}
+
+var _ Store = new(SQLStore)
+
+func NewPostgresStore(db *sqlx.DB) *SQLStore {
+	return &SQLStore{db: db, skipLocked: true}
+}
+
+// NewSQLiteStore is meant for single-instance use. SQLite does not support
+// row locks, so repositories are leased in a transaction instead.
+func NewSQLiteStore(db *sqlx.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) CountRepositories(ctx context.Context) (total, healthy int64, err error) {
+	if err := s.db.GetContext(ctx, &total, "SELECT COUNT(id) FROM repositories"); err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+	if err := s.db.GetContext(ctx, &healthy, "SELECT COUNT(id) FROM repositories WHERE error_code=0"); err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+	return total, healthy, nil
+}
+
+func (s *SQLStore) CountSnapshotQueue(ctx context.Context, due time.Time) (int64, error) {
+	var count int64
+	if err := s.db.GetContext(ctx, &count, s.db.Rebind("SELECT COUNT(id) FROM repositories WHERE last_scrapped_at < ? AND error_code=0"), due); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return count, nil
+}
+
+func (s *SQLStore) CountSnapshots(ctx context.Context) (int64, error) {
+	var count int64
+	if err := s.db.GetContext(ctx, &count, "SELECT COUNT(id) FROM repository_snapshots"); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return count, nil
+}
+
+func (s *SQLStore) repositoryID(ctx context.Context, q sqlx.QueryerContext, registry, slug string) (int, error) {
+	var repository int
+	query := s.db.Rebind("SELECT id FROM repositories WHERE registry=? AND slug=?")
+	if err := sqlx.GetContext(ctx, q, &repository, query, registry, slug); err == sql.ErrNoRows {
+		return 0, errors.WithStack(ErrRepositoryNotFound)
+	} else if err != nil {
+		return 0, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return repository, nil
+}
+
+func (s *SQLStore) ListSnapshots(ctx context.Context, registry, slug string, from, to time.Time) (RepositorySnapshots, error) {
+	repository, err := s.repositoryID(ctx, s.db, registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	var repositories RepositorySnapshots
+	if err := s.db.SelectContext(ctx, &repositories, s.db.Rebind("SELECT * FROM repository_snapshots WHERE repository_id=? AND fetched_at >= ? AND fetched_at <= ? ORDER BY fetched_at ASC"), repository, from, to); err == sql.ErrNoRows {
+		return RepositorySnapshots{}, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return repositories, nil
+}
+
+func (s *SQLStore) LatestSnapshot(ctx context.Context, registry, slug string) (*RepositorySnapshot, error) {
+	repository, err := s.repositoryID(ctx, s.db, registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest RepositorySnapshot
+	if err := s.db.GetContext(ctx, &latest, s.db.Rebind("SELECT * FROM repository_snapshots WHERE repository_id=? ORDER BY fetched_at DESC LIMIT 1"), repository); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &latest, nil
+}
+
+func (s *SQLStore) WalkSnapshots(ctx context.Context, q SnapshotQuery, fn func(*SnapshotRecord) error) error {
+	condition, args := `r.slug LIKE ? ESCAPE '\'`, []interface{}{likePrefix(q.Prefix)}
+	if len(q.Slugs) > 0 {
+		condition, args = "r.slug IN (?)", []interface{}{q.Slugs}
+	}
+
+	query, args, err := sqlx.In(`SELECT r.registry, r.slug, s.*
+FROM repository_snapshots s JOIN repositories r ON r.id = s.repository_id
+WHERE r.registry=? AND `+condition+` AND s.fetched_at >= ? AND s.fetched_at <= ?
+ORDER BY r.slug ASC, s.fetched_at ASC`, append(append([]interface{}{q.Registry}, args...), q.From, q.To)...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	query = s.db.Rebind(query)
+	rows, err := s.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r SnapshotRecord
+		if err := rows.StructScan(&r); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+	return errors.WithStack(rows.Err())
+}
+
+func (s *SQLStore) ImportSnapshots(ctx context.Context, registry, slug string, snapshots RepositorySnapshots) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	repository, err := s.repositoryID(ctx, tx, registry, slug)
+	if err != nil {
+		return 0, err
+	}
+
+	var imported int64
+	query := s.db.Rebind(`INSERT INTO repository_snapshots (repository_id, fetched_at, pulls, pulls_raw, stars)
+SELECT ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM repository_snapshots WHERE repository_id=? AND fetched_at=?)`)
+	for _, r := range snapshots {
+		res, err := tx.ExecContext(ctx, query, repository, r.Timestamp, r.Pulls, r.PullsRaw, r.Stars, repository, r.Timestamp)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		imported += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return int(imported), nil
+}
+
+func (s *SQLStore) AddSnapshot(ctx context.Context, registry, slug string, r *RepositorySnapshot, scrappedAt time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	repository, err := s.repositoryID(ctx, tx, registry, slug)
+	if err != nil {
+		return err
+	}
+
+	var previous *RepositorySnapshot
+	var latest RepositorySnapshot
+	query := s.db.Rebind("SELECT * FROM repository_snapshots WHERE repository_id=? ORDER BY fetched_at DESC LIMIT 1")
+	if err := tx.GetContext(ctx, &latest, query, repository); err == nil {
+		previous = &latest
+	} else if err != sql.ErrNoRows {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	r.Timestamp = time.Now().UTC()
+	r.RepositoryID = repository
+	r.PullsRaw = r.Pulls
+	if r.Pulls, err = correctPulls(r.PullsRaw, previous); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("INSERT INTO repository_snapshots (%s) VALUES (%s)",
+		snapshotInsertColumns,
+		snapshotInsertArguments,
+	)
+	if _, err := tx.NamedExecContext(
+		ctx,
+		query,
+		r,
+	); err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	query = s.db.Rebind("UPDATE repositories SET last_scrapped_at=?, error_kind='', retry_count=0, leased_by='', leased_until=? WHERE id=?")
+	if _, err := tx.ExecContext(
+		ctx,
+		query,
+		scrappedAt,
+		zeroDate,
+		repository,
+	); err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) CorrectPulls(ctx context.Context, registry, slug string) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	repository, err := s.repositoryID(ctx, tx, registry, slug)
+	if err != nil {
+		return 0, err
+	}
+
+	var snapshots RepositorySnapshots
+	query := s.db.Rebind("SELECT * FROM repository_snapshots WHERE repository_id=? ORDER BY fetched_at ASC")
+	if err := tx.SelectContext(ctx, &snapshots, query, repository); err != nil {
+		return 0, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	changed := correctHistory(snapshots)
+	query = s.db.Rebind("UPDATE repository_snapshots SET pulls=? WHERE id=?")
+	for _, snapshot := range changed {
+		if _, err := tx.ExecContext(ctx, query, snapshot.Pulls, snapshot.ID); err != nil {
+			return 0, errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return len(changed), nil
+}
+
+const leaseCondition = "last_scrapped_at < ? AND error_code=0 AND retry_at <= ? AND leased_until < ? ORDER BY last_scrapped_at, id ASC LIMIT ?"
+
+// LeaseRepositories skips rows locked by other instances so that several
+// scrapers can share the table without fetching twice.
+func (s *SQLStore) LeaseRepositories(ctx context.Context, owner string, now, due, leasedUntil time.Time, limit int) ([]Repository, error) {
+	var repositories []Repository
+	if s.skipLocked {
+		query := s.db.Rebind(`UPDATE repositories SET leased_by=?, leased_until=? WHERE id IN (
+	SELECT id FROM repositories WHERE ` + leaseCondition + `
+	FOR UPDATE SKIP LOCKED
+) RETURNING *`)
+		if err := s.db.SelectContext(ctx, &repositories, query, owner, leasedUntil, due, now, now, limit); err != nil {
+			return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+		return repositories, nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	query := s.db.Rebind("SELECT * FROM repositories WHERE " + leaseCondition)
+	if err := tx.SelectContext(ctx, &repositories, query, due, now, now, limit); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	query = s.db.Rebind("UPDATE repositories SET leased_by=?, leased_until=? WHERE id=?")
+	for k, r := range repositories {
+		if _, err := tx.ExecContext(ctx, query, owner, leasedUntil, r.ID); err != nil {
+			return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+		repositories[k].LeasedBy = owner
+		repositories[k].LeasedUntil = leasedUntil
+	}
+
+	return repositories, errors.WithStack(tx.Commit())
+}
+
+func (s *SQLStore) LeaseRepository(ctx context.Context, registry, slug, owner string, now, leasedUntil time.Time) (*Repository, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	var r Repository
+	query := s.db.Rebind("SELECT * FROM repositories WHERE registry=? AND slug=?")
+	if err := tx.GetContext(ctx, &r, query, registry, slug); err == sql.ErrNoRows {
+		return nil, errors.WithStack(ErrRepositoryNotFound)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	query = s.db.Rebind("UPDATE repositories SET leased_by=?, leased_until=? WHERE id=? AND (leased_by=? OR leased_until < ?)")
+	res, err := tx.ExecContext(ctx, query, owner, leasedUntil, r.ID, owner, now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, errors.WithStack(err)
+	} else if n == 0 {
+		return nil, errors.WithStack(ErrRepositoryLeased)
+	}
+
+	r.LeasedBy, r.LeasedUntil = owner, leasedUntil
+	return &r, errors.WithStack(tx.Commit())
+}
+
+func (s *SQLStore) ReleaseRepository(ctx context.Context, registry, slug, owner string) error {
+	query := s.db.Rebind("UPDATE repositories SET leased_by='', leased_until=? WHERE registry=? AND slug=? AND leased_by=?")
+	_, err := s.db.ExecContext(ctx, query, zeroDate, registry, slug, owner)
+	return errors.Wrapf(err, "unable to execute query: %s", query)
+}
+
+func (s *SQLStore) MarkRepositoryError(ctx context.Context, registry, slug string, kind ErrorKind, code int, at time.Time) error {
+	if kind.Permanent() {
+		query := s.db.Rebind("UPDATE repositories SET error_code=?, error_kind=?, error_at=?, leased_by='', leased_until=? WHERE registry=? AND slug=?")
+		res, err := s.db.ExecContext(
+			ctx,
+			query,
+			code,
+			kind,
+			at,
+			zeroDate,
+			registry,
+			slug,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return errors.WithStack(err)
+		} else if n == 0 {
+			return errors.WithStack(ErrRepositoryNotFound)
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	var retries int
+	query := s.db.Rebind("SELECT retry_count FROM repositories WHERE registry=? AND slug=?")
+	if err := tx.GetContext(ctx, &retries, query, registry, slug); err == sql.ErrNoRows {
+		return errors.WithStack(ErrRepositoryNotFound)
+	} else if err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	retries++
+	query = s.db.Rebind("UPDATE repositories SET error_kind=?, error_at=?, retry_count=?, retry_at=?, leased_by='', leased_until=? WHERE registry=? AND slug=?")
+	if _, err := tx.ExecContext(
+		ctx,
+		query,
+		kind,
+		at,
+		retries,
+		at.Add(retryBackoff(retries)),
+		zeroDate,
+		registry,
+		slug,
+	); err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+const repositoryTagColumns = "repository_id, name, digest, architectures, size, last_pushed, fetched_at"
+
+func (s *SQLStore) AddTags(ctx context.Context, registry, slug string, tags RepositoryTags, fetchedAt time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	repository, err := s.repositoryID(ctx, tx, registry, slug)
+	if err != nil {
+		return err
+	}
+
+	upsert := s.db.Rebind(`INSERT INTO repository_tags (` + repositoryTagColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (repository_id, name) DO UPDATE SET digest=excluded.digest, architectures=excluded.architectures, size=excluded.size, last_pushed=excluded.last_pushed, fetched_at=excluded.fetched_at`)
+	push := s.db.Rebind("INSERT INTO repository_tag_pushes (" + repositoryTagColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING")
+	for _, t := range tags {
+		for _, query := range []string{upsert, push} {
+			if _, err := tx.ExecContext(ctx, query, repository, t.Name, t.Digest, t.Architectures, t.Size, t.LastPushed, fetchedAt); err != nil {
+				return errors.Wrapf(err, "unable to execute query: %s", query)
+			}
+		}
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+func (s *SQLStore) ListTags(ctx context.Context, registry, slug string) (RepositoryTags, error) {
+	repository, err := s.repositoryID(ctx, s.db, registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := RepositoryTags{}
+	query := s.db.Rebind("SELECT " + repositoryTagColumns + " FROM repository_tags WHERE repository_id=? ORDER BY last_pushed ASC, name ASC")
+	if err := s.db.SelectContext(ctx, &tags, query, repository); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return tags, nil
+}
+
+func (s *SQLStore) ListTagPushes(ctx context.Context, registry, slug string, from, to time.Time) (RepositoryTags, error) {
+	repository, err := s.repositoryID(ctx, s.db, registry, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	pushes := RepositoryTags{}
+	query := s.db.Rebind("SELECT " + repositoryTagColumns + " FROM repository_tag_pushes WHERE repository_id=? AND last_pushed >= ? AND last_pushed <= ? ORDER BY last_pushed ASC, name ASC")
+	if err := s.db.SelectContext(ctx, &pushes, query, repository, from, to); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return pushes, nil
+}
+
+func (s *SQLStore) AddRepositories(ctx context.Context, registry, source string, slugs []string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	for _, slug := range slugs {
+		if _, err := tx.NamedExecContext(
+			ctx,
+			fmt.Sprintf(
+				"INSERT INTO repositories (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+				repositoryInsertColumns,
+				repositoryInsertArguments,
+			), newRepository(registry, source, slug)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) ImportRepository(ctx context.Context, r *Repository, withErrors bool) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	imported := importedRepository(r, withErrors)
+	if _, err := tx.NamedExecContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO repositories (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+			repositoryInsertColumns,
+			repositoryInsertArguments,
+		), imported); err != nil {
+		return errors.WithStack(err)
+	}
+
+	query := s.db.Rebind("UPDATE repositories SET last_scrapped_at=? WHERE registry=? AND slug=? AND last_scrapped_at < ?")
+	args := []interface{}{r.LastScrappedAt, r.Registry, r.Slug, r.LastScrappedAt}
+	if withErrors {
+		query = s.db.Rebind("UPDATE repositories SET last_scrapped_at=?, error_code=?, error_kind=?, error_at=?, retry_count=?, retry_at=? WHERE registry=? AND slug=? AND last_scrapped_at < ?")
+		args = []interface{}{r.LastScrappedAt, r.ErrorCode, r.ErrorKind, r.ErrorAt, r.RetryCount, r.RetryAt, r.Registry, r.Slug, r.LastScrappedAt}
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// RemoveRepository deletes dependent rows explicitly because SQLite only
+// cascades deletes when foreign keys are enabled.
+func (s *SQLStore) RemoveRepository(ctx context.Context, registry, slug string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	repository, err := s.repositoryID(ctx, tx, registry, slug)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"repository_growth", "repository_snapshots", "repository_tags", "repository_tag_pushes"} {
+		query := s.db.Rebind("DELETE FROM " + table + " WHERE repository_id=?")
+		if _, err := tx.ExecContext(ctx, query, repository); err != nil {
+			return errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+	}
+
+	query := s.db.Rebind("DELETE FROM repositories WHERE id=?")
+	if _, err := tx.ExecContext(ctx, query, repository); err != nil {
+		return errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+// refreshGrowthQuery compares the latest snapshot of every repository with the
+// last snapshot taken before the window started.
+const refreshGrowthQuery = `INSERT INTO repository_growth (repository_id, window_days, pulls, pulls_growth, pulls_growth_relative, stars_growth, computed_at)
+SELECT l.repository_id, ?, l.pulls, l.pulls - b.pulls,
+	CASE WHEN b.pulls > 0 THEN (l.pulls - b.pulls) * 1.0 / b.pulls ELSE 0 END,
+	l.stars - b.stars, ?
+FROM repository_snapshots l
+JOIN (SELECT repository_id, MAX(fetched_at) AS fetched_at FROM repository_snapshots GROUP BY repository_id) lm
+	ON lm.repository_id = l.repository_id AND lm.fetched_at = l.fetched_at
+JOIN (SELECT repository_id, MAX(fetched_at) AS fetched_at FROM repository_snapshots WHERE fetched_at <= ? GROUP BY repository_id) bm
+	ON bm.repository_id = l.repository_id
+JOIN repository_snapshots b
+	ON b.repository_id = bm.repository_id AND b.fetched_at = bm.fetched_at
+ON CONFLICT DO NOTHING`
+
+func (s *SQLStore) RefreshGrowth(ctx context.Context, now time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	for _, window := range LeaderboardWindows {
+		query := s.db.Rebind("DELETE FROM repository_growth WHERE window_days=?")
+		if _, err := tx.ExecContext(ctx, query, window); err != nil {
+			return errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+
+		query = s.db.Rebind(refreshGrowthQuery)
+		if _, err := tx.ExecContext(ctx, query, window, now, now.AddDate(0, 0, -window)); err != nil {
+			return errors.Wrapf(err, "unable to execute query: %s", query)
+		}
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+func (s *SQLStore) Leaderboard(ctx context.Context, q LeaderboardQuery) ([]RepositoryGrowth, error) {
+	leaderboard := []RepositoryGrowth{}
+	query := s.db.Rebind(`SELECT r.registry, r.slug, g.window_days, g.pulls, g.pulls_growth, g.pulls_growth_relative, g.stars_growth, g.computed_at
+FROM repository_growth g JOIN repositories r ON r.id = g.repository_id
+WHERE g.window_days=? AND r.registry=? AND g.pulls >= ?
+ORDER BY g.` + q.Order.column() + ` DESC, r.id ASC LIMIT ?`)
+	if err := s.db.SelectContext(ctx, &leaderboard, query, q.Window, q.Registry, q.MinPulls, q.Limit); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return leaderboard, nil
+}
+
+// likePrefix turns prefix into a pattern for LIKE ? ESCAPE '\'.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+func (s *SQLStore) ListRepositories(ctx context.Context, q RepositoryQuery) ([]Repository, error) {
+	conditions := []string{"registry=?", `slug LIKE ? ESCAPE '\'`, "slug > ?"}
+	args := []interface{}{q.Registry, likePrefix(q.Prefix), q.After}
+	if q.Search != "" {
+		conditions = append(conditions, `slug LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likePrefix(q.Search))
+	}
+	if len(q.Slugs) > 0 {
+		conditions = append(conditions, "slug IN (?)")
+		args = append(args, q.Slugs)
+	}
+	if q.Source != "" {
+		conditions = append(conditions, "source=?")
+		args = append(args, q.Source)
+	}
+	switch q.Status {
+	case RepositoryStatusHealthy:
+		conditions = append(conditions, "error_code=0 AND error_kind=''")
+	case RepositoryStatusRetrying:
+		conditions = append(conditions, "error_code=0 AND error_kind<>''")
+	case RepositoryStatusExcluded:
+		conditions = append(conditions, "error_code<>0")
+	}
+
+	repositories := []Repository{}
+	query, args, err := sqlx.In("SELECT * FROM repositories WHERE "+strings.Join(conditions, " AND ")+" ORDER BY slug ASC LIMIT ?", append(args, q.Limit)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	query = s.db.Rebind(query)
+	if err := s.db.SelectContext(ctx, &repositories, query, args...); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return repositories, nil
+}
+
+func (s *SQLStore) ListGrowth(ctx context.Context, registry string, window int, prefix string) ([]RepositoryGrowth, error) {
+	growth := []RepositoryGrowth{}
+	query := s.db.Rebind(`SELECT r.registry, r.slug, g.window_days, g.pulls, g.pulls_growth, g.pulls_growth_relative, g.stars_growth, g.computed_at
+FROM repository_growth g JOIN repositories r ON r.id = g.repository_id
+WHERE g.window_days=? AND r.registry=? AND r.slug LIKE ? ESCAPE '\'
+ORDER BY r.slug ASC`)
+	if err := s.db.SelectContext(ctx, &growth, query, window, registry, likePrefix(prefix)); err != nil {
+		return nil, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return growth, nil
+}
+
+func (s *SQLStore) SumGrowth(ctx context.Context, registry string, window int, prefix string) (int64, error) {
+	var sum int64
+	query := s.db.Rebind(`SELECT COALESCE(SUM(g.pulls_growth), 0)
+FROM repository_growth g JOIN repositories r ON r.id = g.repository_id
+WHERE g.window_days=? AND r.registry=? AND r.slug LIKE ? ESCAPE '\'`)
+	if err := s.db.GetContext(ctx, &sum, query, window, registry, likePrefix(prefix)); err != nil {
+		return 0, errors.Wrapf(err, "unable to execute query: %s", query)
+	}
+	return sum, nil
+}
@@ -0,0 +1,599 @@
+package scrap
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	migrate "github.com/rubenv/sql-migrate"
+
+	"github.com/aeneasr/dockerstats/migrations"
+)
+
+// testStores runs f against every store implementation so that they fulfill
+// the same contract.
+func testStores(t *testing.T, f func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		f(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := sqlx.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		// Every connection would open a new in-memory database.
+		db.SetMaxOpenConns(1)
+
+		source, dialect, err := migrations.Source("sqlite3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrate.Exec(db.DB, dialect, source, migrate.Up); err != nil {
+			t.Fatal(err)
+		}
+		f(t, NewSQLiteStore(db))
+	})
+}
+
+func findRepository(t *testing.T, s Store, slug string) Repository {
+	repositories, err := s.ListRepositories(context.Background(), RepositoryQuery{
+		Registry: DockerHubRegistry,
+		Slugs:    []string{slug},
+		Status:   RepositoryStatusAll,
+		Limit:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(repositories) != 1 {
+		t.Fatalf("expected repository %s to exist", slug)
+	}
+	return repositories[0]
+}
+
+func leasedSlugs(repositories []Repository) []string {
+	slugs := make([]string, len(repositories))
+	for k, r := range repositories {
+		slugs[k] = r.Slug
+	}
+	return slugs
+}
+
+func TestStoreLeaseRepositories(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		due, until := now.Add(-time.Hour), now.Add(time.Hour)
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a", "a/b", "a/c"}); err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := s.LeaseRepositories(ctx, "first", now, due, until, 2)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(first) != 2 {
+			t.Fatalf("expected 2 leased repositories but got %v", leasedSlugs(first))
+		}
+
+		second, err := s.LeaseRepositories(ctx, "second", now, due, until, 10)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(second) != 1 || second[0].Slug == first[0].Slug || second[0].Slug == first[1].Slug {
+			t.Fatalf("expected the one repository not leased by first but got %v", leasedSlugs(second))
+		}
+
+		if none, err := s.LeaseRepositories(ctx, "third", now, due, until, 10); err != nil {
+			t.Fatal(err)
+		} else if len(none) != 0 {
+			t.Fatalf("expected all repositories to be leased but got %v", leasedSlugs(none))
+		}
+
+		// Only the owner can release its lease.
+		if err := s.ReleaseRepository(ctx, DockerHubRegistry, first[0].Slug, "second"); err != nil {
+			t.Fatal(err)
+		}
+		if none, err := s.LeaseRepositories(ctx, "third", now, due, until, 10); err != nil {
+			t.Fatal(err)
+		} else if len(none) != 0 {
+			t.Fatalf("expected lease to be kept but got %v", leasedSlugs(none))
+		}
+
+		if err := s.ReleaseRepository(ctx, DockerHubRegistry, first[0].Slug, "first"); err != nil {
+			t.Fatal(err)
+		}
+		if released, err := s.LeaseRepositories(ctx, "third", now, due, until, 10); err != nil {
+			t.Fatal(err)
+		} else if len(released) != 1 || released[0].Slug != first[0].Slug {
+			t.Fatalf("expected released repository %s but got %v", first[0].Slug, leasedSlugs(released))
+		}
+
+		// Leases of crashed instances expire.
+		later := until.Add(time.Minute)
+		if expired, err := s.LeaseRepositories(ctx, "fourth", later, later.Add(-time.Hour), later.Add(time.Hour), 10); err != nil {
+			t.Fatal(err)
+		} else if len(expired) != 3 {
+			t.Fatalf("expected all leases to be expired but got %v", leasedSlugs(expired))
+		}
+
+		// Scrapped repositories are not due anymore.
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: 1}, later); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/a"); r.LeasedBy != "" {
+			t.Fatalf("expected AddSnapshot to clear the lease but it is held by %s", r.LeasedBy)
+		}
+		latest := later.Add(time.Hour * 2)
+		if due, err := s.LeaseRepositories(ctx, "fifth", latest, later, latest.Add(time.Hour), 10); err != nil {
+			t.Fatal(err)
+		} else if len(due) != 2 {
+			t.Fatalf("expected only unscrapped repositories to be due but got %v", leasedSlugs(due))
+		}
+	})
+}
+
+func TestStoreLeaseRepository(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		until := now.Add(time.Hour)
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a", "a/b"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.MarkRepositoryError(ctx, DockerHubRegistry, "a/b", ErrorKindGone, 404, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.LeaseRepository(ctx, DockerHubRegistry, "a/unknown", "first", now, until); !errors.Is(err, ErrRepositoryNotFound) {
+			t.Fatalf("expected ErrRepositoryNotFound but got %v", err)
+		}
+		for _, slug := range []string{"a/a", "a/b"} {
+			if r, err := s.LeaseRepository(ctx, DockerHubRegistry, slug, "first", now, until); err != nil {
+				t.Fatal(err)
+			} else if r.Slug != slug || r.LeasedBy != "first" || !r.LeasedUntil.Equal(until) {
+				t.Fatalf("expected %s to be leased by first but got %+v", slug, r)
+			}
+		}
+
+		if _, err := s.LeaseRepository(ctx, DockerHubRegistry, "a/a", "second", now, until); !errors.Is(err, ErrRepositoryLeased) {
+			t.Fatalf("expected ErrRepositoryLeased but got %v", err)
+		}
+		if _, err := s.LeaseRepository(ctx, DockerHubRegistry, "a/a", "first", now, until.Add(time.Hour)); err != nil {
+			t.Fatalf("expected the owner to renew its lease but got %v", err)
+		}
+		if leased, err := s.LeaseRepositories(ctx, "second", now, until, until, 10); err != nil {
+			t.Fatal(err)
+		} else if len(leased) != 0 {
+			t.Fatalf("expected no repository to be leased but got %v", leasedSlugs(leased))
+		}
+
+		if err := s.ReleaseRepository(ctx, DockerHubRegistry, "a/a", "first"); err != nil {
+			t.Fatal(err)
+		}
+		if r, err := s.LeaseRepository(ctx, DockerHubRegistry, "a/a", "second", now, until); err != nil {
+			t.Fatal(err)
+		} else if r.LeasedBy != "second" {
+			t.Fatalf("expected a/a to be leased by second but got %+v", r)
+		}
+	})
+}
+
+func TestStoreImportSnapshots(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+		snapshots := RepositorySnapshots{
+			{Pulls: 20, PullsRaw: 20, Stars: 2, Timestamp: start.Add(time.Hour)},
+			{Pulls: 10, PullsRaw: 10, Stars: 1, Timestamp: start},
+		}
+
+		if _, err := s.ImportSnapshots(ctx, DockerHubRegistry, "a/a", snapshots); !errors.Is(err, ErrRepositoryNotFound) {
+			t.Fatalf("expected ErrRepositoryNotFound but got %v", err)
+		}
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for k, expected := range []int{2, 0} {
+			imported, err := s.ImportSnapshots(ctx, DockerHubRegistry, "a/a", snapshots)
+			if err != nil {
+				t.Fatal(err)
+			} else if imported != expected {
+				t.Fatalf("import %d: expected %d imported snapshots but got %d", k, expected, imported)
+			}
+		}
+
+		stored, err := s.ListSnapshots(ctx, DockerHubRegistry, "a/a", zeroDate, maxDate)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(stored) != 2 || stored[0].Pulls != 10 || stored[1].Pulls != 20 || !stored[0].Timestamp.Equal(start) {
+			t.Fatalf("expected both snapshots in chronological order but got %+v", stored)
+		}
+	})
+}
+
+func TestStoreImportRepository(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		archived := Repository{
+			Registry:       DockerHubRegistry,
+			Slug:           "a/a",
+			Source:         SourceSearch,
+			DiscoveredAt:   at.AddDate(0, -1, 0),
+			LastScrappedAt: at,
+			ErrorKind:      ErrorKindTransient,
+			ErrorAt:        at,
+			RetryCount:     2,
+			RetryAt:        at.Add(time.Hour),
+			LeasedBy:       "other",
+			LeasedUntil:    at.Add(time.Hour),
+		}
+		if err := s.ImportRepository(ctx, &archived, true); err != nil {
+			t.Fatal(err)
+		}
+		r := findRepository(t, s, "a/a")
+		if r.Source != SourceSearch || !r.LastScrappedAt.Equal(at) || r.RetryCount != 2 || !r.RetryAt.Equal(at.Add(time.Hour)) || r.Status() != RepositoryStatusRetrying {
+			t.Fatalf("expected the state to be imported but got %+v", r)
+		}
+		if r.LeasedBy != "" {
+			t.Fatalf("expected the lease not to be imported but got %+v", r)
+		}
+
+		older := archived
+		older.LastScrappedAt, older.ErrorKind, older.RetryCount = at.Add(-time.Hour), ErrorKindGone, 0
+		if err := s.ImportRepository(ctx, &older, true); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/a"); r.ErrorKind != ErrorKindTransient || r.RetryCount != 2 {
+			t.Fatalf("expected older state to be skipped but got %+v", r)
+		}
+
+		newer := archived
+		newer.LastScrappedAt, newer.ErrorKind, newer.ErrorCode, newer.RetryCount = at.Add(time.Hour), ErrorKindGone, 404, 0
+		if err := s.ImportRepository(ctx, &newer, true); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/a"); r.ErrorCode != 404 || r.RetryCount != 0 || r.Status() != RepositoryStatusExcluded {
+			t.Fatalf("expected newer state to be imported but got %+v", r)
+		}
+
+		// Archives without error state only restore when repositories were
+		// last scrapped.
+		withoutErrors := archived
+		withoutErrors.LastScrappedAt = at.Add(time.Hour * 2)
+		if err := s.ImportRepository(ctx, &withoutErrors, false); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/a"); !r.LastScrappedAt.Equal(at.Add(time.Hour*2)) || r.ErrorCode != 404 || r.Status() != RepositoryStatusExcluded {
+			t.Fatalf("expected only the scrap state to be imported but got %+v", r)
+		}
+		withoutErrors.Slug = "a/b"
+		if err := s.ImportRepository(ctx, &withoutErrors, false); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/b"); !r.LastScrappedAt.Equal(at.Add(time.Hour*2)) || r.RetryCount != 0 || r.Status() != RepositoryStatusHealthy {
+			t.Fatalf("expected a/b to be added without errors but got %+v", r)
+		}
+	})
+}
+
+func TestStoreMarkRepositoryError(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a", "a/b"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, kind := range []ErrorKind{ErrorKindTransient, ErrorKindGone} {
+			if err := s.MarkRepositoryError(ctx, DockerHubRegistry, "a/unknown", kind, 0, at); !errors.Is(err, ErrRepositoryNotFound) {
+				t.Fatalf("%s: expected ErrRepositoryNotFound but got %v", kind, err)
+			}
+		}
+
+		for retries, backoff := range []time.Duration{time.Minute * 15, time.Minute * 30, time.Hour} {
+			if err := s.MarkRepositoryError(ctx, DockerHubRegistry, "a/a", ErrorKindTransient, 0, at); err != nil {
+				t.Fatal(err)
+			}
+			r := findRepository(t, s, "a/a")
+			if r.RetryCount != retries+1 || !r.RetryAt.Equal(at.Add(backoff)) || r.Status() != RepositoryStatusRetrying {
+				t.Fatalf("expected retry %d after %s but got %+v", retries+1, backoff, r)
+			}
+		}
+
+		due := at.Add(time.Hour * 24)
+		if leased, err := s.LeaseRepositories(ctx, "x", at.Add(time.Minute*30), due, due, 10); err != nil {
+			t.Fatal(err)
+		} else if len(leased) != 1 || leased[0].Slug != "a/b" {
+			t.Fatalf("expected a/a to wait for its retry but got %v", leasedSlugs(leased))
+		}
+		if leased, err := s.LeaseRepositories(ctx, "x", at.Add(time.Hour), due, due, 10); err != nil {
+			t.Fatal(err)
+		} else if len(leased) != 1 || leased[0].Slug != "a/a" {
+			t.Fatalf("expected a/a to be retried but got %v", leasedSlugs(leased))
+		}
+
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: 1}, at); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/a"); r.RetryCount != 0 || r.Status() != RepositoryStatusHealthy {
+			t.Fatalf("expected AddSnapshot to clear transient errors but got %+v", r)
+		}
+
+		if err := s.MarkRepositoryError(ctx, DockerHubRegistry, "a/b", ErrorKindGone, 404, at); err != nil {
+			t.Fatal(err)
+		}
+		if r := findRepository(t, s, "a/b"); r.ErrorCode != 404 || r.Status() != RepositoryStatusExcluded {
+			t.Fatalf("expected a/b to be excluded but got %+v", r)
+		}
+		later := due.Add(time.Hour * 24 * 30)
+		if leased, err := s.LeaseRepositories(ctx, "x", later, later, later, 10); err != nil {
+			t.Fatal(err)
+		} else if len(leased) != 1 || leased[0].Slug != "a/a" {
+			t.Fatalf("expected excluded repositories to never be leased but got %v", leasedSlugs(leased))
+		}
+	})
+}
+
+func TestStoreListRepositories(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		var slugs []string
+		for k := 0; k < 5; k++ {
+			slugs = append(slugs, fmt.Sprintf("a/%d", k))
+		}
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceDiscovery, slugs[1:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{slugs[0], "b/0"}); err != nil {
+			t.Fatal(err)
+		}
+
+		q := RepositoryQuery{Registry: DockerHubRegistry, Prefix: "a/", Status: RepositoryStatusAll, Limit: 2}
+		var listed []string
+		for page := 0; ; page++ {
+			repositories, err := s.ListRepositories(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			} else if page > len(slugs) {
+				t.Fatal("pagination does not terminate")
+			}
+			listed = append(listed, leasedSlugs(repositories)...)
+			if len(repositories) < q.Limit {
+				break
+			}
+			q.After = repositories[len(repositories)-1].Slug
+		}
+		if fmt.Sprint(listed) != fmt.Sprint(slugs) {
+			t.Fatalf("expected pages to list %v but got %v", slugs, listed)
+		}
+
+		manual, err := s.ListRepositories(ctx, RepositoryQuery{Registry: DockerHubRegistry, Source: SourceManual, Status: RepositoryStatusAll, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		} else if fmt.Sprint(leasedSlugs(manual)) != "[a/0 b/0]" {
+			t.Fatalf("expected manual repositories but got %v", leasedSlugs(manual))
+		}
+
+		if err := s.MarkRepositoryError(ctx, DockerHubRegistry, "a/1", ErrorKindGone, 404, time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+		excluded, err := s.ListRepositories(ctx, RepositoryQuery{Registry: DockerHubRegistry, Status: RepositoryStatusExcluded, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		} else if fmt.Sprint(leasedSlugs(excluded)) != "[a/1]" {
+			t.Fatalf("expected excluded repositories but got %v", leasedSlugs(excluded))
+		}
+	})
+}
+
+func growthSlugs(growth []RepositoryGrowth) []string {
+	slugs := make([]string, len(growth))
+	for k, g := range growth {
+		slugs[k] = g.Slug
+	}
+	return slugs
+}
+
+// addGrowthFixture stores snapshots before, at and inside the windows and
+// refreshes the rollup at now.
+func addGrowthFixture(t *testing.T, s Store, now time.Time) {
+	ctx := context.Background()
+	for registry, history := range map[string]map[string]RepositorySnapshots{
+		DockerHubRegistry: {
+			"a/a": {
+				{Pulls: 1000, Stars: 10, Timestamp: now.AddDate(0, 0, -40)},
+				{Pulls: 2000, Stars: 20, Timestamp: now.AddDate(0, 0, -10)},
+				{Pulls: 3000, Stars: 30, Timestamp: now.AddDate(0, 0, -3)},
+				{Pulls: 3500, Stars: 35, Timestamp: now.Add(-time.Hour)},
+			},
+			"a/b": {
+				{Pulls: 100, Timestamp: now.AddDate(0, 0, -5)},
+				{Pulls: 400, Stars: 1, Timestamp: now.Add(-time.Hour * 2)},
+			},
+			"a/d": {},
+			"b/c": {
+				{Pulls: 50000, Timestamp: now.AddDate(0, 0, -40)},
+				{Pulls: 50500, Timestamp: now.AddDate(0, 0, -7)},
+				{Pulls: 51000, Timestamp: now.Add(-time.Hour * 12)},
+			},
+		},
+		"other": {
+			"a/a": {
+				{Pulls: 0, Timestamp: now.AddDate(0, 0, -40)},
+				{Pulls: 1000000, Timestamp: now.Add(-time.Hour)},
+			},
+		},
+	} {
+		for _, slug := range []string{"a/a", "a/b", "a/d", "b/c"} {
+			snapshots, ok := history[slug]
+			if !ok {
+				continue
+			}
+			if err := s.AddRepositories(ctx, registry, SourceManual, []string{slug}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.ImportSnapshots(ctx, registry, slug, snapshots); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := s.RefreshGrowth(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreRefreshGrowth(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC)
+		addGrowthFixture(t, s, now)
+
+		for _, tc := range []struct {
+			window   int
+			expected map[string]RepositoryGrowth
+		}{
+			{window: 1, expected: map[string]RepositoryGrowth{
+				"a/a": {Pulls: 3500, PullsGrowth: 500, PullsGrowthRelative: 500.0 / 3000, StarsGrowth: 5},
+				"a/b": {Pulls: 400, PullsGrowth: 300, PullsGrowthRelative: 3, StarsGrowth: 1},
+				"b/c": {Pulls: 51000, PullsGrowth: 500, PullsGrowthRelative: 500.0 / 50500},
+			}},
+			{window: 7, expected: map[string]RepositoryGrowth{
+				"a/a": {Pulls: 3500, PullsGrowth: 1500, PullsGrowthRelative: 1500.0 / 2000, StarsGrowth: 15},
+				"b/c": {Pulls: 51000, PullsGrowth: 500, PullsGrowthRelative: 500.0 / 50500},
+			}},
+			{window: 30, expected: map[string]RepositoryGrowth{
+				"a/a": {Pulls: 3500, PullsGrowth: 2500, PullsGrowthRelative: 2.5, StarsGrowth: 25},
+				"b/c": {Pulls: 51000, PullsGrowth: 1000, PullsGrowthRelative: 0.02},
+			}},
+		} {
+			growth, err := s.ListGrowth(ctx, DockerHubRegistry, tc.window, "")
+			if err != nil {
+				t.Fatal(err)
+			} else if len(growth) != len(tc.expected) {
+				t.Fatalf("window %d: expected %d repositories but got %v", tc.window, len(tc.expected), growthSlugs(growth))
+			}
+			for _, g := range growth {
+				e, ok := tc.expected[g.Slug]
+				if !ok || g.Window != tc.window || g.Pulls != e.Pulls || g.PullsGrowth != e.PullsGrowth || g.StarsGrowth != e.StarsGrowth ||
+					math.Abs(g.PullsGrowthRelative-e.PullsGrowthRelative) > 1e-9 || !g.ComputedAt.Equal(now) {
+					t.Fatalf("window %d: expected %+v but got %+v", tc.window, e, g)
+				}
+			}
+		}
+	})
+}
+
+func TestStoreLeaderboard(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		addGrowthFixture(t, s, time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC))
+
+		for _, tc := range []struct {
+			name     string
+			q        LeaderboardQuery
+			expected []string
+		}{
+			{name: "absolute", q: LeaderboardQuery{Window: 1, Order: LeaderboardOrderAbsolute, Limit: 10}, expected: []string{"a/a", "b/c", "a/b"}},
+			{name: "absolute with limit", q: LeaderboardQuery{Window: 7, Order: LeaderboardOrderAbsolute, Limit: 1}, expected: []string{"a/a"}},
+			{name: "absolute month", q: LeaderboardQuery{Window: 30, Order: LeaderboardOrderAbsolute, Limit: 10}, expected: []string{"a/a", "b/c"}},
+			{name: "relative", q: LeaderboardQuery{Window: 1, Order: LeaderboardOrderRelative, Limit: 10}, expected: []string{"a/b", "a/a", "b/c"}},
+			{name: "relative with min pulls", q: LeaderboardQuery{Window: 1, Order: LeaderboardOrderRelative, MinPulls: 1000, Limit: 10}, expected: []string{"a/a", "b/c"}},
+			{name: "min pulls excluding all", q: LeaderboardQuery{Window: 1, Order: LeaderboardOrderRelative, MinPulls: 100000, Limit: 10}, expected: []string{}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.q.Registry = DockerHubRegistry
+				leaderboard, err := s.Leaderboard(ctx, tc.q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual := growthSlugs(leaderboard); fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+					t.Fatalf("expected %v but got %v", tc.expected, actual)
+				}
+			})
+		}
+
+		for _, tc := range []struct {
+			window   int
+			prefix   string
+			expected int64
+			slugs    []string
+		}{
+			{window: 1, expected: 1300, slugs: []string{"a/a", "a/b", "b/c"}},
+			{window: 1, prefix: "a/", expected: 800, slugs: []string{"a/a", "a/b"}},
+			{window: 7, expected: 2000, slugs: []string{"a/a", "b/c"}},
+			{window: 30, prefix: "b/", expected: 1000, slugs: []string{"b/c"}},
+			{window: 30, prefix: "c/", expected: 0, slugs: []string{}},
+		} {
+			sum, err := s.SumGrowth(ctx, DockerHubRegistry, tc.window, tc.prefix)
+			if err != nil {
+				t.Fatal(err)
+			} else if sum != tc.expected {
+				t.Fatalf("window %d, prefix %q: expected sum %d but got %d", tc.window, tc.prefix, tc.expected, sum)
+			}
+			growth, err := s.ListGrowth(ctx, DockerHubRegistry, tc.window, tc.prefix)
+			if err != nil {
+				t.Fatal(err)
+			} else if actual := growthSlugs(growth); fmt.Sprint(actual) != fmt.Sprint(tc.slugs) {
+				t.Fatalf("window %d, prefix %q: expected %v but got %v", tc.window, tc.prefix, tc.slugs, actual)
+			}
+		}
+	})
+}
+
+func TestStoreRemoveRepository(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a", "a/b"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddSnapshot(ctx, DockerHubRegistry, "a/a", &RepositorySnapshot{Pulls: 10}, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTags(ctx, DockerHubRegistry, "a/a", RepositoryTags{{Name: "latest", Digest: "sha256:a", LastPushed: now}}, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RefreshGrowth(ctx, now.AddDate(0, 0, 40)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.RemoveRepository(ctx, DockerHubRegistry, "a/a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RemoveRepository(ctx, DockerHubRegistry, "a/a"); !errors.Is(err, ErrRepositoryNotFound) {
+			t.Fatalf("expected ErrRepositoryNotFound but got %v", err)
+		}
+
+		// New repositories must not inherit anything of the removed one.
+		if err := s.AddRepositories(ctx, DockerHubRegistry, SourceManual, []string{"a/a"}); err != nil {
+			t.Fatal(err)
+		}
+		if snapshots, err := s.ListSnapshots(ctx, DockerHubRegistry, "a/a", zeroDate, maxDate); err != nil {
+			t.Fatal(err)
+		} else if len(snapshots) != 0 {
+			t.Fatalf("expected snapshots to be removed but got %+v", snapshots)
+		}
+		if tags, err := s.ListTags(ctx, DockerHubRegistry, "a/a"); err != nil {
+			t.Fatal(err)
+		} else if len(tags) != 0 {
+			t.Fatalf("expected tags to be removed but got %+v", tags)
+		}
+		if growth, err := s.ListGrowth(ctx, DockerHubRegistry, 7, ""); err != nil {
+			t.Fatal(err)
+		} else if len(growth) != 0 {
+			t.Fatalf("expected growth to be removed but got %+v", growth)
+		}
+		if count, err := s.CountSnapshots(ctx); err != nil {
+			t.Fatal(err)
+		} else if count != 0 {
+			t.Fatalf("expected no snapshots but got %d", count)
+		}
+		findRepository(t, s, "a/b")
+	})
+}
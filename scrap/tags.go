@@ -0,0 +1,154 @@
+package scrap
+
+import (
+	"database/sql/driver"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RepositoryTag is a tag of a repository as it was pushed last.
+type RepositoryTag struct {
+	RepositoryID  int           `json:"-" db:"repository_id"`
+	Name          string        `json:"name" db:"name"`
+	Digest        string        `json:"digest" db:"digest"`
+	Architectures Architectures `json:"architectures" db:"architectures"`
+	// Size is the compressed image size in bytes reported by the registry.
+	Size       int64     `json:"size" db:"size"`
+	LastPushed time.Time `json:"last_pushed" db:"last_pushed"`
+	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+}
+
+// RepositoryTags are ordered by LastPushed.
+type RepositoryTags []*RepositoryTag
+
+// Architectures are stored comma separated, for example "amd64,arm/v7".
+type Architectures []string
+
+func (a Architectures) Value() (driver.Value, error) {
+	return strings.Join(a, ","), nil
+}
+
+func (a *Architectures) Scan(src interface{}) error {
+	var v string
+	switch s := src.(type) {
+	case string:
+		v = s
+	case []byte:
+		v = string(s)
+	case nil:
+	default:
+		return errors.Errorf("unable to scan %T into architectures", src)
+	}
+
+	*a = Architectures{}
+	if v != "" {
+		*a = strings.Split(v, ",")
+	}
+	return nil
+}
+
+// TagCadenceBucket counts the tag pushes within a bucket.
+type TagCadenceBucket struct {
+	Timestamp time.Time `json:"timestamp"`
+	Pushes    int       `json:"pushes"`
+	Tags      []string  `json:"tags"`
+}
+
+// TagCadence is how often new images were pushed to the tags of a repository.
+type TagCadence struct {
+	Resolution Resolution `json:"resolution"`
+	Pushes     int        `json:"pushes"`
+	LastPushed time.Time  `json:"last_pushed"`
+	// MeanInterval is the mean number of days between two pushes.
+	MeanInterval float64            `json:"mean_interval_days"`
+	Buckets      []TagCadenceBucket `json:"buckets"`
+}
+
+// Cadence aggregates the pushes into buckets of the given resolution. Buckets
+// without pushes are omitted.
+func (t RepositoryTags) Cadence(r Resolution) *TagCadence {
+	c := &TagCadence{Resolution: r, Pushes: len(t), Buckets: []TagCadenceBucket{}}
+	if len(t) == 0 {
+		return c
+	}
+
+	for _, tag := range t {
+		start := r.Truncate(tag.LastPushed)
+		if len(c.Buckets) == 0 || !c.Buckets[len(c.Buckets)-1].Timestamp.Equal(start) {
+			c.Buckets = append(c.Buckets, TagCadenceBucket{Timestamp: start})
+		}
+
+		b := &c.Buckets[len(c.Buckets)-1]
+		b.Pushes++
+		b.Tags = append(b.Tags, tag.Name)
+	}
+
+	first, last := t[0].LastPushed, t[len(t)-1].LastPushed
+	c.LastPushed = last
+	if len(t) > 1 {
+		c.MeanInterval = last.Sub(first).Hours() / 24 / float64(len(t)-1)
+	}
+	return c
+}
+
+// TagSizeBucket holds the compressed sizes of the images pushed within a
+// bucket.
+type TagSizeBucket struct {
+	Timestamp time.Time `json:"timestamp"`
+	Pushes    int       `json:"pushes"`
+	Min       int64     `json:"min_size"`
+	Max       int64     `json:"max_size"`
+	Mean      int64     `json:"mean_size"`
+	// Last is the size of the last push within the bucket.
+	Last int64 `json:"last_size"`
+}
+
+// Sizes aggregates the sizes of the pushes into buckets of the given
+// resolution. Buckets without pushes are omitted.
+func (t RepositoryTags) Sizes(r Resolution) []TagSizeBucket {
+	buckets := []TagSizeBucket{}
+	var sum int64
+	for _, tag := range t {
+		start := r.Truncate(tag.LastPushed)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Timestamp.Equal(start) {
+			buckets = append(buckets, TagSizeBucket{Timestamp: start, Min: tag.Size, Max: tag.Size})
+			sum = 0
+		}
+
+		b := &buckets[len(buckets)-1]
+		b.Pushes++
+		sum += tag.Size
+		b.Mean = sum / int64(b.Pushes)
+		b.Last = tag.Size
+		if tag.Size < b.Min {
+			b.Min = tag.Size
+		}
+		if tag.Size > b.Max {
+			b.Max = tag.Size
+		}
+	}
+	return buckets
+}
+
+// Named returns the pushes of the tag with the given name.
+func (t RepositoryTags) Named(name string) RepositoryTags {
+	named := RepositoryTags{}
+	for _, tag := range t {
+		if tag.Name == name {
+			named = append(named, tag)
+		}
+	}
+	return named
+}
+
+func sortTags(t RepositoryTags) {
+	sort.SliceStable(t, func(i, j int) bool {
+		if t[i].LastPushed.Equal(t[j].LastPushed) {
+			return t[i].Name < t[j].Name
+		}
+		return t[i].LastPushed.Before(t[j].LastPushed)
+	})
+}
@@ -13,14 +13,52 @@ type discoverySummaryResult struct {
 	Slug string `json:"slug"`
 }
 
+type tagsResult struct {
+	Next    string      `json:"next"`
+	Results []tagResult `json:"results"`
+}
+
+type tagResult struct {
+	Name          string           `json:"name"`
+	Digest        string           `json:"digest"`
+	FullSize      int64            `json:"full_size"`
+	LastUpdated   time.Time        `json:"last_updated"`
+	TagLastPushed time.Time        `json:"tag_last_pushed"`
+	Images        []tagImageResult `json:"images"`
+}
+
+type tagImageResult struct {
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant"`
+	Digest       string `json:"digest"`
+	Size         int64  `json:"size"`
+}
+
+// Sources record how a repository was added.
+const (
+	// SourceSearch repositories were requested through the API.
+	SourceSearch = "search"
+	// SourceDiscovery repositories were found by crawling a registry.
+	SourceDiscovery = "discovery"
+	// SourceManual repositories were added from a watchlist or by the repos
+	// command.
+	SourceManual = "manual"
+)
+
 type Repository struct {
 	ID             int       `json:"-" db:"id"`
+	Registry       string    `json:"registry" db:"registry"`
 	Slug           string    `json:"slug" db:"slug"`
 	LastScrappedAt time.Time `json:"last_scrapped_at" db:"last_scrapped_at"`
 	DiscoveredAt   time.Time `json:"discovered_at" db:"discovered_at"`
 	Source         string    `json:"source" db:"source"`
 	ErrorCode      int       `json:"-" db:"error_code"`
+	ErrorKind      ErrorKind `json:"error_kind,omitempty" db:"error_kind"`
 	ErrorAt        time.Time `json:"-" db:"error_at"`
+	RetryCount     int       `json:"-" db:"retry_count"`
+	RetryAt        time.Time `json:"-" db:"retry_at"`
+	LeasedUntil    time.Time `json:"-" db:"leased_until"`
+	LeasedBy       string    `json:"-" db:"leased_by"`
 }
 
 type RepositorySnapshot struct {
@@ -28,5 +66,33 @@ type RepositorySnapshot struct {
 	RepositoryID int       `json:"-" db:"repository_id"`
 	Stars        int64     `json:"star_count" db:"stars"`
 	Pulls        int64     `json:"pull_count" db:"pulls"`
+	PullsRaw     int64     `json:"pull_count_raw" db:"pulls_raw"`
 	Timestamp    time.Time `json:"timestamp" db:"fetched_at"`
 }
+
+// importedRepository copies r without its lease and, unless withErrors is set,
+// without its error and retry state.
+func importedRepository(r *Repository, withErrors bool) *Repository {
+	imported := *r
+	imported.ID = 0
+	imported.LeasedBy, imported.LeasedUntil = "", zeroDate
+	if !withErrors {
+		imported.ErrorCode, imported.ErrorKind, imported.ErrorAt = 0, "", zeroDate
+		imported.RetryCount, imported.RetryAt = 0, zeroDate
+	}
+	return &imported
+}
+
+func newRepository(registry, source, slug string) *Repository {
+	return &Repository{
+		Registry:       registry,
+		Source:         source,
+		Slug:           slug,
+		DiscoveredAt:   time.Now().UTC(),
+		LastScrappedAt: zeroDate,
+		ErrorAt:        zeroDate,
+		ErrorCode:      0,
+		RetryAt:        zeroDate,
+		LeasedUntil:    zeroDate,
+	}
+}